@@ -0,0 +1,52 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"strconv"
+)
+
+// the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+// get the sockets passed to this process by systemd socket activation
+// (see sd_listen_fds(3)), so that a Server can be started on port 67
+// without running as root, and can be restarted without missing any
+// messages. pass each of them to Server.StartConn.
+//
+// nil is returned if no sockets were passed. the environment variables
+// used to pass them are cleared, so they are not passed on to children
+func ListenFDs() ([]net.PacketConn, error) {
+	return listenFDs(listenFDsStart)
+}
+
+func listenFDs(start int) ([]net.PacketConn, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil // not for us
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	conns := make([]net.PacketConn, 0, n)
+	for fd := start; fd < start+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		conn, err := net.FilePacketConn(f)
+		f.Close() // conn has its own copy
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return nil, errors.Wrapf(err, "use passed file descriptor %d", fd)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
@@ -0,0 +1,55 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package jdhcp
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenFDs(t *testing.T) {
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	conns, err := ListenFDs()
+	if err != nil || conns != nil {
+		t.Errorf("expected no sockets for another process, got %v %v", conns, err)
+	}
+
+	sock, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	defer sock.Close()
+	f, err := sock.File()
+	if err != nil {
+		t.Fatalf("could not get socket file: %s", err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatalf("could not duplicate socket: %s", err)
+	}
+
+	// pretend the socket was passed at fd, which listenFDs closes
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	conns, err = listenFDs(fd)
+	if err != nil {
+		t.Fatalf("listenFDs returned error: %s", err)
+	}
+	if len(conns) != 1 {
+		t.Fatalf("expected 1 socket, got %d", len(conns))
+	}
+	defer conns[0].Close()
+
+	if conns[0].LocalAddr().String() != sock.LocalAddr().String() {
+		t.Errorf("expected socket on %s, got %s", sock.LocalAddr(), conns[0].LocalAddr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected LISTEN_FDS to be cleared")
+	}
+}
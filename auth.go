@@ -0,0 +1,162 @@
+package jdhcp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"github.com/pkg/errors"
+)
+
+// protocols, algorithms and replay detection methods of the
+// authentication option (RFC3118)
+const (
+	AuthConfigurationToken byte = 0 // the token is sent in the clear
+	AuthDelayed            byte = 1 // a keyed hash of the message
+
+	AuthHMACMD5 byte = 1 // the only algorithm of AuthDelayed
+
+	AuthMonotonic byte = 0 // the replay detection value increases with each message
+)
+
+// the length of the authentication information of AuthDelayed with
+// AuthHMACMD5, a secret ID and the HMAC
+const delayedAuthInfoLen = 4 + md5.Size
+
+// an Authentication is the content of option 90
+type Authentication struct {
+	Protocol        byte
+	Algorithm       byte
+	RDM             byte   // replay detection method
+	ReplayDetection uint64 // increases with each message when RDM is AuthMonotonic
+	Info            []byte // depends on Protocol and Algorithm
+}
+
+// create an Authentication for delayed authentication with
+// HMAC-MD5, using the key identified by secretID. the HMAC is left as
+// zeroes, to be filled in by SignDelayedAuth once the message is marshaled
+func NewDelayedAuth(secretID uint32, replay uint64) *Authentication {
+	info := make([]byte, delayedAuthInfoLen)
+	binary.BigEndian.PutUint32(info, secretID)
+	return &Authentication{
+		Protocol:        AuthDelayed,
+		Algorithm:       AuthHMACMD5,
+		RDM:             AuthMonotonic,
+		ReplayDetection: replay,
+		Info:            info,
+	}
+}
+
+// the secret ID of delayed authentication, which identifies the key
+// the HMAC was computed with
+func (a *Authentication) SecretID() (uint32, error) {
+	if a.Protocol != AuthDelayed || a.Algorithm != AuthHMACMD5 || len(a.Info) != delayedAuthInfoLen {
+		return 0, errors.New("not delayed authentication with hmac-md5")
+	}
+	return binary.BigEndian.Uint32(a.Info), nil
+}
+
+// option 90
+func (o Options) Authentication() (*Authentication, error) {
+	v, ok := o[OptionAuthentication]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 11 {
+		return nil, &OptionError{OptionAuthentication, ErrShortRead}
+	}
+	return &Authentication{
+		Protocol:        v[0],
+		Algorithm:       v[1],
+		RDM:             v[2],
+		ReplayDetection: binary.BigEndian.Uint64(v[3:11]),
+		Info:            v[11:],
+	}, nil
+}
+
+// set option 90
+func (o Options) SetAuthentication(a *Authentication) error {
+	if 11+len(a.Info) > 255 {
+		return &OptionError{OptionAuthentication,
+			errors.Errorf("authentication information of %d bytes is too long", len(a.Info))}
+	}
+	v := make([]byte, 11, 11+len(a.Info))
+	v[0], v[1], v[2] = a.Protocol, a.Algorithm, a.RDM
+	binary.BigEndian.PutUint64(v[3:11], a.ReplayDetection)
+	o[OptionAuthentication] = append(v, a.Info...)
+	return nil
+}
+
+// fill in the HMAC of the delayed authentication option in the
+// marshaled message data, computed with key
+func SignDelayedAuth(data, key []byte) error {
+	mac, field, err := delayedAuthMAC(data, key)
+	if err != nil {
+		return err
+	}
+	copy(data[field:], mac)
+	return nil
+}
+
+// check the HMAC of the delayed authentication option in the
+// marshaled message data against key. the key can be chosen with the
+// SecretID of the option, and the ReplayDetection should be checked
+// to be higher than that of the previous message
+func VerifyDelayedAuth(data, key []byte) error {
+	mac, field, err := delayedAuthMAC(data, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, data[field:field+md5.Size]) {
+		return errors.New("bad authentication hmac")
+	}
+	return nil
+}
+
+// compute the HMAC of delayed authentication over the marshaled
+// message data, returning it along with the offset of the HMAC in
+// data. the HMAC covers the message with hops, giaddr and the HMAC
+// itself zeroed, so that relays do not invalidate it (RFC3118 chapter 5.2)
+func delayedAuthMAC(data, key []byte) ([]byte, int, error) {
+	v, off, err := findOption(data, OptionAuthentication)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(v) != 11+delayedAuthInfoLen || v[0] != AuthDelayed || v[1] != AuthHMACMD5 {
+		return nil, 0, &OptionError{OptionAuthentication, errors.New("not delayed authentication with hmac-md5")}
+	}
+	field := off + 11 + 4
+
+	b := append([]byte(nil), data...)
+	b[3] = 0
+	copy(b[24:28], []byte{0, 0, 0, 0})
+	copy(b[field:field+md5.Size], make([]byte, md5.Size))
+
+	mac := hmac.New(md5.New, key)
+	mac.Write(b)
+	return mac.Sum(nil), field, nil
+}
+
+// find the option code in the options field of the marshaled message
+// data, returning its value and the offset of the value in data
+func findOption(data []byte, code OptionCode) ([]byte, int, error) {
+	if len(data) < 240 {
+		return nil, 0, &ParseError{Offset: len(data), Field: "header", Err: ErrShortRead}
+	}
+	for i := 240; i < len(data); {
+		switch OptionCode(data[i]) {
+		case OptionEnd:
+			return nil, 0, ErrOptionNotPresent
+		case OptionPad:
+			i++
+			continue
+		}
+		if i+1 >= len(data) || i+2+int(data[i+1]) > len(data) {
+			return nil, 0, &ParseError{Offset: i, Field: "options", Err: ErrShortRead}
+		}
+		if OptionCode(data[i]) == code {
+			return data[i+2 : i+2+int(data[i+1])], i + 2, nil
+		}
+		i += 2 + int(data[i+1])
+	}
+	return nil, 0, ErrOptionNotPresent
+}
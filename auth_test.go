@@ -0,0 +1,55 @@
+package jdhcp
+
+import (
+	"testing"
+)
+
+func TestDelayedAuth(t *testing.T) {
+	key := []byte("secret key")
+
+	msg := NewMsg()
+	msg.Hlen = 6
+	msg.Options[OptionDHCPMessageType] = []byte{byte(Request)}
+	msg.Options.SetAuthentication(NewDelayedAuth(7, 42))
+	data, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("msg.MarshalBytes() returned error: %s", err)
+	}
+	if err := SignDelayedAuth(data, key); err != nil {
+		t.Fatalf("SignDelayedAuth() returned error: %s", err)
+	}
+
+	// a relay may change hops and giaddr
+	data[3] = 1
+	copy(data[24:28], []byte{10, 0, 0, 1})
+	if err := VerifyDelayedAuth(data, key); err != nil {
+		t.Fatalf("VerifyDelayedAuth() returned error: %s", err)
+	}
+
+	res, err := ParseMsg(data)
+	if err != nil {
+		t.Fatalf("ParseMsg() returned error: %s", err)
+	}
+	a, err := res.Options.Authentication()
+	if err != nil {
+		t.Fatalf("res.Options.Authentication() returned error: %s", err)
+	}
+	id, err := a.SecretID()
+	if err != nil || id != 7 || a.ReplayDetection != 42 || a.RDM != AuthMonotonic {
+		t.Errorf("unexpected authentication %+v, secret id %d, error %v", a, id, err)
+	}
+
+	if err := VerifyDelayedAuth(data, []byte("other key")); err == nil {
+		t.Errorf("expected error for the wrong key")
+	}
+	data[11] ^= 1
+	if err := VerifyDelayedAuth(data, key); err == nil {
+		t.Errorf("expected error for a modified message")
+	}
+
+	delete(msg.Options, OptionAuthentication)
+	data, _ = msg.MarshalBytes()
+	if err := SignDelayedAuth(data, key); err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent, got %v", err)
+	}
+}
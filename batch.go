@@ -0,0 +1,116 @@
+package jdhcp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// a BatchHandler is a MsgHandler which is given the messages received
+// over a short window at once, so that a slow backend (such as a lease
+// database) can deal with a burst of them in one go. it returns a
+// response for each message in the same position, with nil meaning
+// there is no response. missing responses are treated as nil.
+//
+// the messages are recycled in the same way as for a MsgCallback, and
+// the context is cancelled when the Server is stopped or when the
+// handler timeout runs out
+type BatchHandler func(context.Context, []Msg) []*Msg
+
+// register a BatchHandler with the Server, replacing any callback.
+// messages are collected for up to window after the first one of a
+// batch arrives, or until there are size of them, whichever is sooner.
+// a size of zero means only the window limits a batch
+func (l *Server) RegisterBatchHandler(h BatchHandler, window time.Duration, size int) {
+	b := &batcher{server: l, handler: h, window: window, size: size}
+	l.RegisterHandler(b.handle)
+}
+
+// a batcher collects the messages of a BatchHandler
+type batcher struct {
+	server  *Server
+	handler BatchHandler
+	window  time.Duration
+	size    int
+
+	mu      sync.Mutex
+	pending []batchReq
+	timer   *time.Timer
+}
+
+// a message waiting in a batch, and where to send its response
+type batchReq struct {
+	msg Msg
+	res chan *Msg
+}
+
+// add req to the current batch and wait for its response. this waits
+// even after the context of req is done, as the batch may still be using it
+func (b *batcher) handle(_ context.Context, req Msg) *Msg {
+	r := batchReq{msg: req, res: make(chan *Msg, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, r)
+	if b.size > 0 && len(b.pending) >= b.size {
+		batch := b.take()
+		b.mu.Unlock()
+		go b.run(batch)
+	} else {
+		if len(b.pending) == 1 {
+			b.timer = time.AfterFunc(b.window, b.flush)
+		}
+		b.mu.Unlock()
+	}
+
+	return <-r.res
+}
+
+// run the current batch once its window is over
+func (b *batcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.run(batch)
+	}
+}
+
+// take the current batch, so that the next message starts a new one.
+// the caller must hold mu
+func (b *batcher) take() []batchReq {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// call the handler with batch and send each response to its waiter
+func (b *batcher) run(batch []batchReq) {
+	b.server.cbMutex.RLock()
+	timeout := b.server.timeout
+	b.server.cbMutex.RUnlock()
+
+	ctx := b.server.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	msgs := make([]Msg, len(batch))
+	for i, r := range batch {
+		msgs[i] = r.msg
+	}
+	res := b.handler(ctx, msgs)
+
+	for i, r := range batch {
+		if i < len(res) {
+			r.res <- res[i]
+		} else {
+			r.res <- nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package jdhcp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run one message per xid through the handler of serv at once,
+// returning the xid of each response
+func handleConcurrently(serv *Server, xids []uint32) []uint32 {
+	got := make([]uint32, len(xids))
+	var wg sync.WaitGroup
+	for i, xid := range xids {
+		wg.Add(1)
+		go func(i int, xid uint32) {
+			defer wg.Done()
+			req := NewMsg()
+			req.Xid = xid
+			if res := serv.msgCb(context.Background(), *req); res != nil {
+				got[i] = res.Xid
+			}
+		}(i, xid)
+	}
+	wg.Wait()
+	return got
+}
+
+func TestBatchHandler(t *testing.T) {
+	serv := NewServer(WithLogger(testLogg))
+
+	var mu sync.Mutex
+	var sizes []int
+	serv.RegisterBatchHandler(func(_ context.Context, reqs []Msg) []*Msg {
+		mu.Lock()
+		sizes = append(sizes, len(reqs))
+		mu.Unlock()
+
+		// reply to all but the message with xid 3
+		res := make([]*Msg, len(reqs))
+		for i, req := range reqs {
+			if req.Xid != 3 {
+				res[i] = NewMsg()
+				res[i].Xid = req.Xid
+			}
+		}
+		return res
+	}, time.Hour, 4)
+
+	// a full batch is sent without waiting for the window
+	got := handleConcurrently(serv, []uint32{1, 2, 3, 4})
+	for i, xid := range []uint32{1, 2, 0, 4} {
+		if got[i] != xid {
+			t.Errorf("message %d: expected response %d got %d", i, xid, got[i])
+		}
+	}
+	if len(sizes) != 1 || sizes[0] != 4 {
+		t.Errorf("expected one batch of 4 got %v", sizes)
+	}
+}
+
+func TestBatchHandlerWindow(t *testing.T) {
+	serv := NewServer(WithLogger(testLogg))
+
+	var mu sync.Mutex
+	var sizes []int
+	serv.RegisterBatchHandler(func(_ context.Context, reqs []Msg) []*Msg {
+		mu.Lock()
+		sizes = append(sizes, len(reqs))
+		mu.Unlock()
+		return nil // no responses
+	}, 20*time.Millisecond, 0)
+
+	got := handleConcurrently(serv, []uint32{1, 2})
+	for i, xid := range got {
+		if xid != 0 {
+			t.Errorf("message %d: expected no response got %d", i, xid)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	if total != 2 {
+		t.Errorf("expected 2 messages in batches got %v", sizes)
+	}
+}
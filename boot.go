@@ -0,0 +1,283 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Arch is a client system architecture type, as sent by network
+// boot clients in option 93 (RFC4578 and the IANA registry)
+type Arch uint16
+
+const (
+	ArchX86BIOS       Arch = 0
+	ArchX86UEFI       Arch = 6
+	ArchX64UEFI       Arch = 7
+	ArchEBC           Arch = 9
+	ArchARM32UEFI     Arch = 10
+	ArchARM64UEFI     Arch = 11
+	ArchX86UEFIHTTP   Arch = 15
+	ArchX64UEFIHTTP   Arch = 16
+	ArchEBCHTTP       Arch = 17
+	ArchARM32UEFIHTTP Arch = 18
+	ArchARM64UEFIHTTP Arch = 19
+	ArchX86BIOSHTTP   Arch = 20
+)
+
+var archNames = map[Arch]string{
+	ArchX86BIOS:       "x86 BIOS",
+	ArchX86UEFI:       "x86 UEFI",
+	ArchX64UEFI:       "x64 UEFI",
+	ArchEBC:           "EBC",
+	ArchARM32UEFI:     "ARM 32-bit UEFI",
+	ArchARM64UEFI:     "ARM 64-bit UEFI",
+	ArchX86UEFIHTTP:   "x86 UEFI HTTP",
+	ArchX64UEFIHTTP:   "x64 UEFI HTTP",
+	ArchEBCHTTP:       "EBC HTTP",
+	ArchARM32UEFIHTTP: "ARM 32-bit UEFI HTTP",
+	ArchARM64UEFIHTTP: "ARM 64-bit UEFI HTTP",
+	ArchX86BIOSHTTP:   "x86 BIOS HTTP",
+}
+
+func (a Arch) String() string {
+	if name, ok := archNames[a]; ok {
+		return name
+	}
+	return "Arch " + strconv.Itoa(int(a))
+}
+
+// option 93
+func (o Options) ClientArchitectures() ([]Arch, error) {
+	v, ok := o[OptionClientSystemArchitecture]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 2 || len(v)%2 != 0 {
+		return nil, &OptionError{OptionClientSystemArchitecture, ErrShortRead}
+	}
+
+	archs := make([]Arch, 0, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		archs = append(archs, Arch(v[i])<<8|Arch(v[i+1]))
+	}
+	return archs, nil
+}
+
+// set option 93
+func (o Options) SetClientArchitectures(archs []Arch) error {
+	if len(archs) == 0 {
+		return &OptionError{OptionClientSystemArchitecture, errors.New("at least one architecture is needed")}
+	}
+	v := make([]byte, 0, 2*len(archs))
+	for _, a := range archs {
+		v = append(v, byte(a>>8), byte(a))
+	}
+	o[OptionClientSystemArchitecture] = v
+	return nil
+}
+
+// a NetworkInterfaceID is the network interface of a boot client in
+// option 94 (RFC4578 chapter 2.2). Type 1 is UNDI, which is the only
+// one defined, and the version is that of the UNDI it implements
+type NetworkInterfaceID struct {
+	Type         byte
+	Major, Minor byte
+}
+
+// option 94
+func (o Options) ClientNetworkInterface() (NetworkInterfaceID, error) {
+	v, ok := o[OptionClientNetworkInterface]
+	if !ok {
+		return NetworkInterfaceID{}, ErrOptionNotPresent
+	}
+	if len(v) != 3 {
+		return NetworkInterfaceID{}, &OptionError{OptionClientNetworkInterface, errors.Errorf("length %d is not 3", len(v))}
+	}
+	return NetworkInterfaceID{v[0], v[1], v[2]}, nil
+}
+
+// set option 94
+func (o Options) SetClientNetworkInterface(id NetworkInterfaceID) {
+	o[OptionClientNetworkInterface] = []byte{id.Type, id.Major, id.Minor}
+}
+
+// option 97, the 16-byte GUID of a boot client (RFC4578 chapter 2.3),
+// without the leading type byte. it is returned as sent, which for
+// most clients is the SMBIOS UUID with its first three fields little endian
+func (o Options) ClientMachineID() ([]byte, error) {
+	v, ok := o[OptionClientMachineID]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) != 17 {
+		return nil, &OptionError{OptionClientMachineID, errors.Errorf("length %d is not 17", len(v))}
+	}
+	if v[0] != 0 {
+		return nil, &OptionError{OptionClientMachineID, errors.Errorf("unknown type %d", v[0])}
+	}
+	return v[1:], nil
+}
+
+// set option 97 to the 16-byte guid, adding the type byte
+func (o Options) SetClientMachineID(guid []byte) error {
+	if len(guid) != 16 {
+		return &OptionError{OptionClientMachineID, errors.Errorf("guid length %d is not 16", len(guid))}
+	}
+	o[OptionClientMachineID] = append([]byte{0}, guid...)
+	return nil
+}
+
+// the vendor class of UEFI HTTP Boot clients and their responses
+const httpClientClass = "HTTPClient"
+
+// check whether req is from a UEFI HTTP Boot client, which sends a
+// vendor class such as "HTTPClient:Arch:00016:UNDI:003001"
+func IsHTTPBootRequest(req *Msg) bool {
+	class, _ := req.VendorClassIdentifier()
+	return strings.HasPrefix(class, httpClientClass)
+}
+
+// HTTPBoot gives UEFI HTTP Boot clients the URI to boot from, which
+// can be http or https. the URI is chosen by the architecture of the
+// client, falling back to Default, as a client can only boot an image
+// built for it. Validate should be called once it is set up
+type HTTPBoot struct {
+	URIs    map[Arch]string
+	Default string
+}
+
+// check that each URI fits in option 67
+func (h *HTTPBoot) Validate() error {
+	if len(h.Default) > 255 {
+		return &OptionError{OptionBootfileName,
+			errors.Errorf("default uri of %d bytes is longer than 255", len(h.Default))}
+	}
+	for a, uri := range h.URIs {
+		if len(uri) > 255 {
+			return &OptionError{OptionBootfileName,
+				errors.Errorf("uri for %s of %d bytes is longer than 255", a, len(uri))}
+		}
+	}
+	return nil
+}
+
+// set the boot URI in the response res to the HTTP Boot request req.
+// the URI goes in the file field, or the bootfile name option if it
+// is too long. false is returned if req is not an HTTP Boot request,
+// or there is no URI for its architecture which passes Validate
+func (h *HTTPBoot) Apply(req, res *Msg) bool {
+	if !IsHTTPBootRequest(req) {
+		return false
+	}
+
+	uri := h.Default
+	if archs, err := req.ClientArchitectures(); err == nil {
+		for _, a := range archs {
+			if u, ok := h.URIs[a]; ok {
+				uri = u
+				break
+			}
+		}
+	}
+	if uri == "" || len(uri) > 255 {
+		return false
+	}
+
+	// the client ignores the offer unless it echoes the class
+	res.Options[OptionVendorClassIdentifier] = []byte(httpClientClass)
+	if len(uri) < 128 {
+		res.File = uri
+	} else {
+		res.Options[OptionBootfileName] = []byte(uri)
+	}
+	return true
+}
+
+// the vendor class of PXE clients and their responses
+const pxeClientClass = "PXEClient"
+
+// check whether req is from a network boot client, using either PXE
+// or UEFI HTTP Boot
+func IsNetbootRequest(req *Msg) bool {
+	class, _ := req.VendorClassIdentifier()
+	return strings.HasPrefix(class, pxeClientClass) || IsHTTPBootRequest(req)
+}
+
+// a BootConfig is what a network boot client of one architecture is
+// told to boot. File is the boot file name, or the URI for HTTP Boot
+type BootConfig struct {
+	File       string
+	NextServer net.IP  // the TFTP server, nil to leave siaddr alone
+	Options    Options // extra options, such as a PXE menu in option 43
+}
+
+// a BootPolicy maps the architecture of a network boot client
+// (option 93) to what it should boot. clients which do not send their
+// architecture are treated as x86 BIOS, as only old PXE clients omit it.
+// Server.SetBootPolicy checks it with Validate, which should be done
+// before calling Apply directly
+type BootPolicy map[Arch]BootConfig
+
+// check that the boot file of each BootConfig fits in option 67
+// and that its options can be encoded
+func (p BootPolicy) Validate() error {
+	for a, conf := range p {
+		if len(conf.File) > 255 {
+			return errors.Wrapf(&OptionError{OptionBootfileName,
+				errors.Errorf("length %d is more than 255", len(conf.File))}, "boot config for %s", a)
+		}
+		for k, v := range conf.Options {
+			if len(v) > 255 {
+				return errors.Wrapf(&OptionError{k,
+					errors.Errorf("length %d is more than 255", len(v))}, "boot config for %s", a)
+			}
+		}
+	}
+	return nil
+}
+
+// apply the BootConfig for the architecture of the network boot
+// request req to its response res. false is returned if req is not
+// from a network boot client, or there is no config for it
+func (p BootPolicy) Apply(req, res *Msg) bool {
+	if !IsNetbootRequest(req) {
+		return false
+	}
+
+	archs, err := req.ClientArchitectures()
+	if err != nil {
+		archs = []Arch{ArchX86BIOS}
+	}
+	var conf BootConfig
+	found := false
+	for _, a := range archs {
+		if conf, found = p[a]; found {
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	// the client ignores the offer unless it echoes the class
+	class := pxeClientClass
+	if IsHTTPBootRequest(req) {
+		class = httpClientClass
+	}
+	res.Options[OptionVendorClassIdentifier] = []byte(class)
+
+	if len(conf.File) < 128 {
+		res.File = conf.File
+	} else {
+		res.Options[OptionBootfileName] = []byte(conf.File)
+	}
+	if conf.NextServer != nil {
+		res.Siaddr = conf.NextServer
+	}
+	for k, v := range conf.Options {
+		res.Options[k] = v
+	}
+	return true
+}
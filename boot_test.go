@@ -0,0 +1,221 @@
+package jdhcp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClientArchitectures(t *testing.T) {
+	o := Options{OptionClientSystemArchitecture: {0x00, 0x10, 0x00, 0x07}}
+	archs, err := o.ClientArchitectures()
+	if err != nil {
+		t.Fatalf("o.ClientArchitectures() returned error: %s", err)
+	}
+	if len(archs) != 2 || archs[0] != ArchX64UEFIHTTP || archs[1] != ArchX64UEFI {
+		t.Errorf("expected [x64 UEFI HTTP, x64 UEFI] got %v", archs)
+	}
+
+	o[OptionClientSystemArchitecture] = []byte{0x00}
+	_, err = o.ClientArchitectures()
+	if err == nil {
+		t.Error("expected an error for a bad length")
+	}
+
+	err = o.SetClientArchitectures([]Arch{ArchARM64UEFI, ArchX86BIOS})
+	if err != nil {
+		t.Fatalf("o.SetClientArchitectures() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionClientSystemArchitecture], []byte{0x00, 0x0b, 0x00, 0x00}) {
+		t.Errorf("unexpected value % x", o[OptionClientSystemArchitecture])
+	}
+}
+
+func TestClientNetworkInterface(t *testing.T) {
+	o := make(Options)
+	o.SetClientNetworkInterface(NetworkInterfaceID{Type: 1, Major: 3, Minor: 16})
+	id, err := o.ClientNetworkInterface()
+	if err != nil {
+		t.Fatalf("o.ClientNetworkInterface() returned error: %s", err)
+	}
+	if id.Type != 1 || id.Major != 3 || id.Minor != 16 {
+		t.Errorf("unexpected interface %+v", id)
+	}
+
+	o[OptionClientNetworkInterface] = []byte{1, 2}
+	if _, err := o.ClientNetworkInterface(); err == nil {
+		t.Error("expected an error for a bad length")
+	}
+}
+
+func TestClientMachineID(t *testing.T) {
+	o := make(Options)
+	guid := []byte{0x4c, 0x4c, 0x45, 0x44, 0x00, 0x4e, 0x32, 0x10,
+		0x80, 0x31, 0xb6, 0xc0, 0x4f, 0x4e, 0x4d, 0x32}
+	err := o.SetClientMachineID(guid)
+	if err != nil {
+		t.Fatalf("o.SetClientMachineID() returned error: %s", err)
+	}
+	if len(o[OptionClientMachineID]) != 17 || o[OptionClientMachineID][0] != 0 {
+		t.Fatalf("unexpected value % x", o[OptionClientMachineID])
+	}
+
+	got, err := o.ClientMachineID()
+	if err != nil {
+		t.Fatalf("o.ClientMachineID() returned error: %s", err)
+	}
+	if !bytes.Equal(got, guid) {
+		t.Errorf("expected % x got % x", guid, got)
+	}
+
+	o[OptionClientMachineID][0] = 1
+	if _, err := o.ClientMachineID(); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+	if err := o.SetClientMachineID(guid[:8]); err == nil {
+		t.Error("expected an error for a short guid")
+	}
+}
+
+func TestHTTPBoot(t *testing.T) {
+	long := "https://boot.example.com/" + strings.Repeat("x", 120) + ".efi"
+	h := &HTTPBoot{
+		URIs: map[Arch]string{
+			ArchX64UEFIHTTP:   "http://boot.example.com/x64.efi",
+			ArchARM64UEFIHTTP: long,
+		},
+	}
+
+	for i, tc := range []struct {
+		class string
+		arch  []byte
+		ok    bool
+		file  string
+		opt67 string
+	}{
+		// 0 x64 client is given its uri in the file field
+		{"HTTPClient:Arch:00016:UNDI:003001", []byte{0, 16}, true,
+			"http://boot.example.com/x64.efi", ""},
+		// 1 long uri goes in option 67
+		{"HTTPClient:Arch:00019:UNDI:003001", []byte{0, 19}, true, "", long},
+		// 2 no uri for this architecture
+		{"HTTPClient:Arch:00015:UNDI:003001", []byte{0, 15}, false, "", ""},
+		// 3 pxe clients are not answered
+		{"PXEClient:Arch:00007:UNDI:003016", []byte{0, 7}, false, "", ""},
+	} {
+		req := NewMsg()
+		req.Options[OptionVendorClassIdentifier] = []byte(tc.class)
+		req.Options[OptionClientSystemArchitecture] = tc.arch
+		res := NewMsg()
+
+		ok := h.Apply(req, res)
+		if ok != tc.ok {
+			t.Errorf("case %d: expected %t got %t", i, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if string(res.Options[OptionVendorClassIdentifier]) != "HTTPClient" {
+			t.Errorf("case %d: response does not have the HTTPClient class", i)
+		}
+		if res.File != tc.file || string(res.Options[OptionBootfileName]) != tc.opt67 {
+			t.Errorf("case %d: wrong uri, file %q option 67 %q", i, res.File, res.Options[OptionBootfileName])
+		}
+	}
+}
+
+func TestHTTPBootValidate(t *testing.T) {
+	h := &HTTPBoot{
+		URIs:    map[Arch]string{ArchX64UEFIHTTP: "http://boot.example.com/x64.efi"},
+		Default: "http://boot.example.com/" + strings.Repeat("x", 200),
+	}
+	if err := h.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	tooLong := "http://boot.example.com/" + strings.Repeat("x", 240)
+	h.URIs[ArchARM64UEFIHTTP] = tooLong
+	if err := h.Validate(); err == nil {
+		t.Errorf("expected error for a uri of %d bytes", len(tooLong))
+	}
+
+	// such a uri is not applied, as the reply could not be sent
+	req := NewMsg()
+	req.Options[OptionVendorClassIdentifier] = []byte("HTTPClient:Arch:00019:UNDI:003001")
+	req.Options[OptionClientSystemArchitecture] = []byte{0, 19}
+	if h.Apply(req, NewMsg()) {
+		t.Errorf("expected a uri of %d bytes not to be applied", len(tooLong))
+	}
+
+	h = &HTTPBoot{Default: tooLong}
+	if err := h.Validate(); err == nil {
+		t.Errorf("expected error for a default uri of %d bytes", len(tooLong))
+	}
+}
+
+func TestBootPolicy(t *testing.T) {
+	tftp := net.IPv4(10, 0, 0, 5)
+	p := BootPolicy{
+		ArchX86BIOS:     {File: "pxelinux.0", NextServer: tftp},
+		ArchX64UEFI:     {File: "grubx64.efi", NextServer: tftp},
+		ArchX64UEFIHTTP: {File: "http://boot.example.com/x64.efi"},
+	}
+
+	for i, tc := range []struct {
+		class string
+		arch  []byte
+		file  string // empty if the policy does not apply
+		echo  string
+	}{
+		{"PXEClient:Arch:00000:UNDI:002001", []byte{0, 0}, "pxelinux.0", "PXEClient"},
+		{"PXEClient", nil, "pxelinux.0", "PXEClient"},
+		{"PXEClient:Arch:00007:UNDI:003016", []byte{0, 7}, "grubx64.efi", "PXEClient"},
+		{"HTTPClient:Arch:00016:UNDI:003001", []byte{0, 16}, "http://boot.example.com/x64.efi", "HTTPClient"},
+		{"PXEClient:Arch:00011:UNDI:003000", []byte{0, 11}, "", ""},
+		{"MSFT 5.0", nil, "", ""},
+	} {
+		req := NewMsg()
+		req.Options[OptionVendorClassIdentifier] = []byte(tc.class)
+		if tc.arch != nil {
+			req.Options[OptionClientSystemArchitecture] = tc.arch
+		}
+		res := NewMsg()
+
+		ok := p.Apply(req, res)
+		if ok != (tc.file != "") {
+			t.Errorf("case %d: expected policy to apply: %t", i, tc.file != "")
+			continue
+		}
+		if res.File != tc.file {
+			t.Errorf("case %d: expected file %q got %q", i, tc.file, res.File)
+		}
+		if ok && string(res.Options[OptionVendorClassIdentifier]) != tc.echo {
+			t.Errorf("case %d: expected class %q got %q", i, tc.echo, res.Options[OptionVendorClassIdentifier])
+		}
+		if ok && tc.echo == "PXEClient" && !res.Siaddr.Equal(tftp) {
+			t.Errorf("case %d: expected next server %s got %s", i, tftp, res.Siaddr)
+		}
+	}
+}
+
+func TestBootPolicyValidate(t *testing.T) {
+	p := BootPolicy{ArchX64UEFIHTTP: {File: "https://boot.example.com/" + strings.Repeat("x", 200) + ".efi"}}
+	if err := p.Validate(); err != nil {
+		t.Errorf("unexpected error for a file in option 67: %s", err)
+	}
+
+	p[ArchX64UEFIHTTP] = BootConfig{File: strings.Repeat("x", 256)}
+	if err := p.Validate(); err == nil {
+		t.Errorf("expected error for a file of 256 bytes")
+	}
+	if err := NewServer().SetBootPolicy(p); err == nil {
+		t.Errorf("expected SetBootPolicy to reject the policy")
+	}
+
+	p[ArchX64UEFIHTTP] = BootConfig{File: "grubx64.efi", Options: Options{OptionVendorSpecificInformation: make([]byte, 256)}}
+	if err := p.Validate(); err == nil {
+		t.Errorf("expected error for an option of 256 bytes")
+	}
+}
@@ -0,0 +1,65 @@
+// jdhcpdump prints the contents of DHCP messages in a readable form.
+// each argument is the name of a file containing a single message,
+// either as raw bytes or (with -hex) as hexadecimal text. with no
+// arguments, a message is read from standard input.
+//
+// malformed messages are decoded as far as possible, and the
+// problems found are listed after the message.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/aktungmak/jdhcp"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	isHex := flag.Bool("hex", false, "input is hexadecimal text")
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	status := 0
+	for _, f := range files {
+		err := dump(f, *isHex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jdhcpdump: %s: %s\n", f, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func dump(name string, isHex bool) error {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if isHex {
+		data, err = hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+		if err != nil {
+			return err
+		}
+	}
+
+	msg, problems := jdhcp.ParseMsgPartial(data)
+	fmt.Print(jdhcp.DecodeMsg(msg))
+	for _, p := range problems {
+		fmt.Printf("problem: %s\n", p)
+	}
+	return nil
+}
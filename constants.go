@@ -2,30 +2,357 @@ package jdhcp
 
 import (
 	"errors"
+	"strconv"
 )
 
 var (
 	ErrOptionNotPresent = errors.New("option not present")
 	ErrShortRead        = errors.New("short read")
+	ErrNotIPv4          = errors.New("not an IPv4 address")
+	ErrLimitExceeded    = errors.New("parse limit exceeded")
 
 	Cookie uint32 = 0x63825363
 )
 
+const (
+	// the message size every client must be able to accept,
+	// including the IP and UDP headers (RFC2131 chapter 2)
+	DefaultMaxMessageSize = 576
+
+	// size of the IP and UDP headers in front of a DHCP message
+	ipUDPHeaderLen = 28
+
+	// well known UDP ports used by servers (and relays) and clients
+	ServerPort = 67
+	ClientPort = 68
+)
+
 type OptionCode byte
 
+// the option codes assigned by IANA (BOOTP Vendor Extensions and DHCP
+// Options registry). codes 128-135 are left to PXE vendors (RFC4578),
+// 224-254 are reserved for site-specific use, of which 249 and 252 are
+// listed here because they are widely used by clients
 const (
 	OptionPad OptionCode = 0
 	OptionEnd OptionCode = 255
 
-	OptionSubnetMask           OptionCode = 1
-	OptionRequestedIPAddress   OptionCode = 50
-	OptionDHCPMessageType      OptionCode = 53
-	OptionParameterRequestList OptionCode = 55
-	OptionRenewalTime          OptionCode = 58
-	OptionRebindingTime        OptionCode = 59
-	OptionClientID             OptionCode = 61
+	OptionSubnetMask                        OptionCode = 1
+	OptionTimeOffset                        OptionCode = 2
+	OptionRouter                            OptionCode = 3
+	OptionTimeServer                        OptionCode = 4
+	OptionNameServer                        OptionCode = 5
+	OptionDomainNameServer                  OptionCode = 6
+	OptionLogServer                         OptionCode = 7
+	OptionCookieServer                      OptionCode = 8
+	OptionLPRServer                         OptionCode = 9
+	OptionImpressServer                     OptionCode = 10
+	OptionResourceLocationServer            OptionCode = 11
+	OptionHostName                          OptionCode = 12
+	OptionBootFileSize                      OptionCode = 13
+	OptionMeritDumpFile                     OptionCode = 14
+	OptionDomainName                        OptionCode = 15
+	OptionSwapServer                        OptionCode = 16
+	OptionRootPath                          OptionCode = 17
+	OptionExtensionsPath                    OptionCode = 18
+	OptionIPForwarding                      OptionCode = 19
+	OptionNonLocalSourceRouting             OptionCode = 20
+	OptionPolicyFilter                      OptionCode = 21
+	OptionMaximumDatagramReassemblySize     OptionCode = 22
+	OptionDefaultIPTTL                      OptionCode = 23
+	OptionPathMTUAgingTimeout               OptionCode = 24
+	OptionPathMTUPlateauTable               OptionCode = 25
+	OptionInterfaceMTU                      OptionCode = 26
+	OptionAllSubnetsAreLocal                OptionCode = 27
+	OptionBroadcastAddress                  OptionCode = 28
+	OptionPerformMaskDiscovery              OptionCode = 29
+	OptionMaskSupplier                      OptionCode = 30
+	OptionPerformRouterDiscovery            OptionCode = 31
+	OptionRouterSolicitationAddress         OptionCode = 32
+	OptionStaticRoute                       OptionCode = 33
+	OptionTrailerEncapsulation              OptionCode = 34
+	OptionARPCacheTimeout                   OptionCode = 35
+	OptionEthernetEncapsulation             OptionCode = 36
+	OptionTCPDefaultTTL                     OptionCode = 37
+	OptionTCPKeepaliveInterval              OptionCode = 38
+	OptionTCPKeepaliveGarbage               OptionCode = 39
+	OptionNISDomain                         OptionCode = 40
+	OptionNISServers                        OptionCode = 41
+	OptionNTPServers                        OptionCode = 42
+	OptionVendorSpecificInformation         OptionCode = 43
+	OptionNetBIOSNameServer                 OptionCode = 44
+	OptionNetBIOSDatagramDistributionServer OptionCode = 45
+	OptionNetBIOSNodeType                   OptionCode = 46
+	OptionNetBIOSScope                      OptionCode = 47
+	OptionXWindowFontServer                 OptionCode = 48
+	OptionXWindowDisplayManager             OptionCode = 49
+	OptionRequestedIPAddress                OptionCode = 50
+	OptionIPAddressLeaseTime                OptionCode = 51
+	OptionOverload                          OptionCode = 52
+	OptionDHCPMessageType                   OptionCode = 53
+	OptionServerIdentifier                  OptionCode = 54
+	OptionParameterRequestList              OptionCode = 55
+	OptionMessage                           OptionCode = 56
+	OptionMaximumMessageSize                OptionCode = 57
+	OptionRenewalTime                       OptionCode = 58
+	OptionRebindingTime                     OptionCode = 59
+	OptionVendorClassIdentifier             OptionCode = 60
+	OptionClientID                          OptionCode = 61
+	OptionNetWareIPDomain                   OptionCode = 62
+	OptionNetWareIPInformation              OptionCode = 63
+	OptionNISPlusDomain                     OptionCode = 64
+	OptionNISPlusServers                    OptionCode = 65
+	OptionTFTPServerName                    OptionCode = 66
+	OptionBootfileName                      OptionCode = 67
+	OptionMobileIPHomeAgent                 OptionCode = 68
+	OptionSMTPServer                        OptionCode = 69
+	OptionPOP3Server                        OptionCode = 70
+	OptionNNTPServer                        OptionCode = 71
+	OptionWWWServer                         OptionCode = 72
+	OptionFingerServer                      OptionCode = 73
+	OptionIRCServer                         OptionCode = 74
+	OptionStreetTalkServer                  OptionCode = 75
+	OptionSTDAServer                        OptionCode = 76
+	OptionUserClass                         OptionCode = 77
+	OptionSLPDirectoryAgent                 OptionCode = 78
+	OptionSLPServiceScope                   OptionCode = 79
+	OptionRapidCommit                       OptionCode = 80
+	OptionClientFQDN                        OptionCode = 81
+	OptionRelayAgentInformation             OptionCode = 82
+	OptionISNS                              OptionCode = 83
+	OptionNDSServers                        OptionCode = 85
+	OptionNDSTreeName                       OptionCode = 86
+	OptionNDSContext                        OptionCode = 87
+	OptionBCMCSControllerDomainList         OptionCode = 88
+	OptionBCMCSControllerAddress            OptionCode = 89
+	OptionAuthentication                    OptionCode = 90
+	OptionClientLastTransactionTime         OptionCode = 91
+	OptionAssociatedIP                      OptionCode = 92
+	OptionClientSystemArchitecture          OptionCode = 93
+	OptionClientNetworkInterface            OptionCode = 94
+	OptionLDAPServers                       OptionCode = 95
+	OptionClientMachineID                   OptionCode = 97
+	OptionUserAuthentication                OptionCode = 98
+	OptionGeoConfCivic                      OptionCode = 99
+	OptionPCode                             OptionCode = 100
+	OptionTCode                             OptionCode = 101
+	OptionIPv6OnlyPreferred                 OptionCode = 108
+	OptionDHCP4o6S46SourceAddress           OptionCode = 109
+	OptionNetinfoAddress                    OptionCode = 112
+	OptionNetinfoTag                        OptionCode = 113
+	OptionCaptivePortal                     OptionCode = 114
+	OptionAutoConfigure                     OptionCode = 116
+	OptionNameServiceSearch                 OptionCode = 117
+	OptionSubnetSelection                   OptionCode = 118
+	OptionDomainSearch                      OptionCode = 119
+	OptionSIPServers                        OptionCode = 120
+	OptionClasslessStaticRoute              OptionCode = 121
+	OptionCableLabsClientConfiguration      OptionCode = 122
+	OptionGeoConf                           OptionCode = 123
+	OptionVIVendorClass                     OptionCode = 124
+	OptionVIVendorSpecificInformation       OptionCode = 125
+	OptionPANAAgent                         OptionCode = 136
+	OptionLoSTServer                        OptionCode = 137
+	OptionCAPWAPAccessController            OptionCode = 138
+	OptionMoSAddress                        OptionCode = 139
+	OptionMoSFQDN                           OptionCode = 140
+	OptionSIPUAConfigurationDomains         OptionCode = 141
+	OptionANDSFAddress                      OptionCode = 142
+	OptionSZTPRedirect                      OptionCode = 143
+	OptionGeoLoc                            OptionCode = 144
+	OptionForcerenewNonceCapable            OptionCode = 145
+	OptionRDNSSSelection                    OptionCode = 146
+	OptionDOTSReferenceIdentifier           OptionCode = 147
+	OptionDOTSAddress                       OptionCode = 148
+	OptionTFTPServerAddress                 OptionCode = 150
+	OptionStatusCode                        OptionCode = 151
+	OptionBaseTime                          OptionCode = 152
+	OptionStartTimeOfState                  OptionCode = 153
+	OptionQueryStartTime                    OptionCode = 154
+	OptionQueryEndTime                      OptionCode = 155
+	OptionDHCPState                         OptionCode = 156
+	OptionDataSource                        OptionCode = 157
+	OptionPCPServer                         OptionCode = 158
+	OptionPortParams                        OptionCode = 159
+	OptionMUDURL                            OptionCode = 161
+	OptionDNR                               OptionCode = 162
+	OptionPXELinuxMagic                     OptionCode = 208
+	OptionConfigurationFile                 OptionCode = 209
+	OptionPathPrefix                        OptionCode = 210
+	OptionRebootTime                        OptionCode = 211
+	Option6RD                               OptionCode = 212
+	OptionAccessDomain                      OptionCode = 213
+	OptionSubnetAllocation                  OptionCode = 220
+	OptionVirtualSubnetSelection            OptionCode = 221
+	OptionMSClasslessStaticRoute            OptionCode = 249
+	OptionWPAD                              OptionCode = 252
 )
 
+// names of the options, used when displaying messages
+var optionNames = map[OptionCode]string{
+	OptionPad:                               "Pad",
+	OptionEnd:                               "End",
+	OptionSubnetMask:                        "Subnet Mask",
+	OptionTimeOffset:                        "Time Offset",
+	OptionRouter:                            "Router",
+	OptionTimeServer:                        "Time Server",
+	OptionNameServer:                        "Name Server",
+	OptionDomainNameServer:                  "Domain Name Server",
+	OptionLogServer:                         "Log Server",
+	OptionCookieServer:                      "Cookie Server",
+	OptionLPRServer:                         "LPR Server",
+	OptionImpressServer:                     "Impress Server",
+	OptionResourceLocationServer:            "Resource Location Server",
+	OptionHostName:                          "Host Name",
+	OptionBootFileSize:                      "Boot File Size",
+	OptionMeritDumpFile:                     "Merit Dump File",
+	OptionDomainName:                        "Domain Name",
+	OptionSwapServer:                        "Swap Server",
+	OptionRootPath:                          "Root Path",
+	OptionExtensionsPath:                    "Extensions Path",
+	OptionIPForwarding:                      "IP Forwarding",
+	OptionNonLocalSourceRouting:             "Non-Local Source Routing",
+	OptionPolicyFilter:                      "Policy Filter",
+	OptionMaximumDatagramReassemblySize:     "Maximum Datagram Reassembly Size",
+	OptionDefaultIPTTL:                      "Default IP Time-to-live",
+	OptionPathMTUAgingTimeout:               "Path MTU Aging Timeout",
+	OptionPathMTUPlateauTable:               "Path MTU Plateau Table",
+	OptionInterfaceMTU:                      "Interface MTU",
+	OptionAllSubnetsAreLocal:                "All Subnets are Local",
+	OptionBroadcastAddress:                  "Broadcast Address",
+	OptionPerformMaskDiscovery:              "Perform Mask Discovery",
+	OptionMaskSupplier:                      "Mask Supplier",
+	OptionPerformRouterDiscovery:            "Perform Router Discovery",
+	OptionRouterSolicitationAddress:         "Router Solicitation Address",
+	OptionStaticRoute:                       "Static Route",
+	OptionTrailerEncapsulation:              "Trailer Encapsulation",
+	OptionARPCacheTimeout:                   "ARP Cache Timeout",
+	OptionEthernetEncapsulation:             "Ethernet Encapsulation",
+	OptionTCPDefaultTTL:                     "TCP Default TTL",
+	OptionTCPKeepaliveInterval:              "TCP Keepalive Interval",
+	OptionTCPKeepaliveGarbage:               "TCP Keepalive Garbage",
+	OptionNISDomain:                         "Network Information Service Domain",
+	OptionNISServers:                        "Network Information Servers",
+	OptionNTPServers:                        "Network Time Protocol Servers",
+	OptionVendorSpecificInformation:         "Vendor-Specific Information",
+	OptionNetBIOSNameServer:                 "NetBIOS over TCP/IP Name Server",
+	OptionNetBIOSDatagramDistributionServer: "NetBIOS over TCP/IP Datagram Distribution Server",
+	OptionNetBIOSNodeType:                   "NetBIOS over TCP/IP Node Type",
+	OptionNetBIOSScope:                      "NetBIOS over TCP/IP Scope",
+	OptionXWindowFontServer:                 "X Window System Font Server",
+	OptionXWindowDisplayManager:             "X Window System Display Manager",
+	OptionRequestedIPAddress:                "Requested IP Address",
+	OptionIPAddressLeaseTime:                "IP Address Lease Time",
+	OptionOverload:                          "Option Overload",
+	OptionDHCPMessageType:                   "DHCP Message Type",
+	OptionServerIdentifier:                  "Server Identifier",
+	OptionParameterRequestList:              "Parameter Request List",
+	OptionMessage:                           "Message",
+	OptionMaximumMessageSize:                "Maximum DHCP Message Size",
+	OptionRenewalTime:                       "Renewal (T1) Time",
+	OptionRebindingTime:                     "Rebinding (T2) Time",
+	OptionVendorClassIdentifier:             "Vendor Class Identifier",
+	OptionClientID:                          "Client Identifier",
+	OptionNetWareIPDomain:                   "NetWare/IP Domain Name",
+	OptionNetWareIPInformation:              "NetWare/IP Information",
+	OptionNISPlusDomain:                     "Network Information Service+ Domain",
+	OptionNISPlusServers:                    "Network Information Service+ Servers",
+	OptionTFTPServerName:                    "TFTP Server Name",
+	OptionBootfileName:                      "Bootfile Name",
+	OptionMobileIPHomeAgent:                 "Mobile IP Home Agent",
+	OptionSMTPServer:                        "SMTP Server",
+	OptionPOP3Server:                        "POP3 Server",
+	OptionNNTPServer:                        "NNTP Server",
+	OptionWWWServer:                         "Default WWW Server",
+	OptionFingerServer:                      "Default Finger Server",
+	OptionIRCServer:                         "Default IRC Server",
+	OptionStreetTalkServer:                  "StreetTalk Server",
+	OptionSTDAServer:                        "StreetTalk Directory Assistance Server",
+	OptionUserClass:                         "User Class",
+	OptionSLPDirectoryAgent:                 "SLP Directory Agent",
+	OptionSLPServiceScope:                   "SLP Service Scope",
+	OptionRapidCommit:                       "Rapid Commit",
+	OptionClientFQDN:                        "Client FQDN",
+	OptionRelayAgentInformation:             "Relay Agent Information",
+	OptionISNS:                              "Internet Storage Name Service",
+	OptionNDSServers:                        "NDS Servers",
+	OptionNDSTreeName:                       "NDS Tree Name",
+	OptionNDSContext:                        "NDS Context",
+	OptionBCMCSControllerDomainList:         "BCMCS Controller Domain Name List",
+	OptionBCMCSControllerAddress:            "BCMCS Controller IPv4 Address",
+	OptionAuthentication:                    "Authentication",
+	OptionClientLastTransactionTime:         "Client Last Transaction Time",
+	OptionAssociatedIP:                      "Associated IP",
+	OptionClientSystemArchitecture:          "Client System Architecture",
+	OptionClientNetworkInterface:            "Client Network Interface Identifier",
+	OptionLDAPServers:                       "LDAP Servers",
+	OptionClientMachineID:                   "Client Machine Identifier",
+	OptionUserAuthentication:                "User Authentication Protocol",
+	OptionGeoConfCivic:                      "Civic Location",
+	OptionPCode:                             "IEEE 1003.1 TZ String",
+	OptionTCode:                             "Reference to the TZ Database",
+	OptionIPv6OnlyPreferred:                 "IPv6-Only Preferred",
+	OptionDHCP4o6S46SourceAddress:           "DHCPv4 over DHCPv6 Softwire Source Address",
+	OptionNetinfoAddress:                    "NetInfo Parent Server Address",
+	OptionNetinfoTag:                        "NetInfo Parent Server Tag",
+	OptionCaptivePortal:                     "Captive Portal",
+	OptionAutoConfigure:                     "Auto-Configure",
+	OptionNameServiceSearch:                 "Name Service Search",
+	OptionSubnetSelection:                   "Subnet Selection",
+	OptionDomainSearch:                      "Domain Search",
+	OptionSIPServers:                        "SIP Servers",
+	OptionClasslessStaticRoute:              "Classless Static Route",
+	OptionCableLabsClientConfiguration:      "CableLabs Client Configuration",
+	OptionGeoConf:                           "GeoConf",
+	OptionVIVendorClass:                     "V-I Vendor Class",
+	OptionVIVendorSpecificInformation:       "V-I Vendor-Specific Information",
+	OptionPANAAgent:                         "PANA Authentication Agent",
+	OptionLoSTServer:                        "LoST Server",
+	OptionCAPWAPAccessController:            "CAPWAP Access Controller",
+	OptionMoSAddress:                        "MoS IPv4 Address",
+	OptionMoSFQDN:                           "MoS FQDN",
+	OptionSIPUAConfigurationDomains:         "SIP UA Configuration Service Domains",
+	OptionANDSFAddress:                      "ANDSF IPv4 Address",
+	OptionSZTPRedirect:                      "SZTP Redirect",
+	OptionGeoLoc:                            "GeoLoc",
+	OptionForcerenewNonceCapable:            "FORCERENEW Nonce Capable",
+	OptionRDNSSSelection:                    "RDNSS Selection",
+	OptionDOTSReferenceIdentifier:           "DOTS Reference Identifier",
+	OptionDOTSAddress:                       "DOTS Address",
+	OptionTFTPServerAddress:                 "TFTP Server Address",
+	OptionStatusCode:                        "Status Code",
+	OptionBaseTime:                          "Base Time",
+	OptionStartTimeOfState:                  "Start Time of State",
+	OptionQueryStartTime:                    "Query Start Time",
+	OptionQueryEndTime:                      "Query End Time",
+	OptionDHCPState:                         "DHCP State",
+	OptionDataSource:                        "Data Source",
+	OptionPCPServer:                         "PCP Server",
+	OptionPortParams:                        "Port Parameters",
+	OptionMUDURL:                            "Manufacturer Usage Description",
+	OptionDNR:                               "Encrypted DNS Server",
+	OptionPXELinuxMagic:                     "PXELINUX Magic",
+	OptionConfigurationFile:                 "Configuration File",
+	OptionPathPrefix:                        "Path Prefix",
+	OptionRebootTime:                        "Reboot Time",
+	Option6RD:                               "6RD",
+	OptionAccessDomain:                      "Access Network Domain Name",
+	OptionSubnetAllocation:                  "Subnet Allocation",
+	OptionVirtualSubnetSelection:            "Virtual Subnet Selection",
+	OptionMSClasslessStaticRoute:            "Microsoft Classless Static Route",
+	OptionWPAD:                              "Web Proxy Auto-Discovery",
+}
+
+// String returns the name of the option followed by its code,
+// for example "Router (3)"
+func (c OptionCode) String() string {
+	name, ok := optionNames[c]
+	if !ok {
+		name = "Unknown"
+	}
+	return name + " (" + strconv.Itoa(int(c)) + ")"
+}
+
 type MessageType byte
 
 const (
@@ -37,4 +364,27 @@ const (
 	NAK      MessageType = 6
 	Release  MessageType = 7
 	Inform   MessageType = 8
+
+	// leasequery (RFC4388)
+	LeaseQuery      MessageType = 10
+	LeaseUnassigned MessageType = 11
+	LeaseUnknown    MessageType = 12
+	LeaseActive     MessageType = 13
 )
+
+// names of the message types, used when displaying messages
+var messageTypeNames = map[MessageType]string{
+	Discover: "DHCPDISCOVER",
+	Offer:    "DHCPOFFER",
+	Request:  "DHCPREQUEST",
+	Decline:  "DHCPDECLINE",
+	ACK:      "DHCPACK",
+	NAK:      "DHCPNAK",
+	Release:  "DHCPRELEASE",
+	Inform:   "DHCPINFORM",
+
+	LeaseQuery:      "DHCPLEASEQUERY",
+	LeaseUnassigned: "DHCPLEASEUNASSIGNED",
+	LeaseUnknown:    "DHCPLEASEUNKNOWN",
+	LeaseActive:     "DHCPLEASEACTIVE",
+}
@@ -0,0 +1,328 @@
+package jdhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a Node is one element of the decoded form of a message, as
+// produced by DecodeMsg. it has a name, a human readable value
+// and possibly some children, such as the sub-options of an option
+type Node struct {
+	Name     string
+	Value    string
+	Children []*Node
+}
+
+// render the Node and all of its children as an indented tree,
+// one Node per line
+func (n *Node) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return b.String()
+}
+
+func (n *Node) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Name)
+	if n.Value != "" {
+		b.WriteString(": ")
+		b.WriteString(n.Value)
+	}
+	b.WriteByte('\n')
+	for _, c := range n.Children {
+		c.write(b, depth+1)
+	}
+}
+
+// decode a Msg into a tree describing the header fields and every
+// option by name, with the sub-options of encapsulating options
+// (such as 43, 82 and 125) expanded as children
+func DecodeMsg(m *Msg) *Node {
+	return &Node{
+		Name: "DHCP message",
+		Children: []*Node{
+			{Name: "op", Value: strconv.Itoa(int(m.Op))},
+			{Name: "htype", Value: strconv.Itoa(int(m.Htype))},
+			{Name: "hlen", Value: strconv.Itoa(int(m.Hlen))},
+			{Name: "hops", Value: strconv.Itoa(int(m.Hops))},
+			{Name: "xid", Value: fmt.Sprintf("0x%08x", m.Xid)},
+			{Name: "secs", Value: strconv.Itoa(int(m.Secs))},
+			{Name: "flags", Value: fmt.Sprintf("0x%04x", m.Flags)},
+			{Name: "ciaddr", Value: m.Ciaddr.String()},
+			{Name: "yiaddr", Value: m.Yiaddr.String()},
+			{Name: "siaddr", Value: m.Siaddr.String()},
+			{Name: "giaddr", Value: m.Giaddr.String()},
+			{Name: "chaddr", Value: m.Chaddr.String()},
+			{Name: "sname", Value: strconv.Quote(m.Sname)},
+			{Name: "file", Value: strconv.Quote(m.File)},
+			{Name: "options", Children: DecodeOptions(m.Options)},
+		},
+	}
+}
+
+// decode each option in o, in order of their codes
+func DecodeOptions(o Options) []*Node {
+	ks := o.keys(nil)
+	nodes := make([]*Node, 0, len(ks))
+	for _, k := range ks {
+		nodes = append(nodes, decodeOption(k, o[k]))
+	}
+	return nodes
+}
+
+func decodeOption(code OptionCode, v []byte) *Node {
+	n := &Node{Name: code.String()}
+
+	if dec, ok := subOptionDecoders[code]; ok {
+		children, err := dec(v)
+		if err == nil {
+			n.Children = children
+			return n
+		}
+		n.Value = fmt.Sprintf("%s (%s)", formatBytes(v), err)
+		return n
+	}
+
+	if f, ok := optionFormatters[code]; ok {
+		n.Value = f(v)
+	} else {
+		n.Value = formatBytes(v)
+	}
+	return n
+}
+
+// functions to format the values of options with a known
+// layout, if a value doesn't match the layout it is shown as bytes
+var optionFormatters = map[OptionCode]func([]byte) string{
+	OptionSubnetMask:           formatIPs,
+	OptionRouter:               formatIPs,
+	OptionDomainNameServer:     formatIPs,
+	OptionInterfaceMTU:         formatUint,
+	OptionBroadcastAddress:     formatIPs,
+	OptionNTPServers:           formatIPs,
+	OptionNetBIOSNameServer:    formatIPs,
+	OptionNetBIOSNodeType:      formatUint,
+	OptionRequestedIPAddress:   formatIPs,
+	OptionIPAddressLeaseTime:   formatSeconds,
+	OptionOverload:             formatUint,
+	OptionDHCPMessageType:      formatMessageType,
+	OptionServerIdentifier:     formatIPs,
+	OptionParameterRequestList: formatCodes,
+	OptionMaximumMessageSize:   formatUint,
+	OptionRenewalTime:          formatSeconds,
+	OptionRebindingTime:        formatSeconds,
+	OptionAutoConfigure:        formatUint,
+	OptionSubnetSelection:      formatIPs,
+
+	OptionClasslessStaticRoute:   formatRoutes(OptionClasslessStaticRoute),
+	OptionCAPWAPAccessController: formatIPs,
+	OptionTFTPServerAddress:      formatIPs,
+	Option6RD:                    formatSixRD,
+	OptionMSClasslessStaticRoute: formatRoutes(OptionMSClasslessStaticRoute),
+
+	OptionClientLastTransactionTime: formatDuration,
+	OptionAssociatedIP:              formatIPs,
+}
+
+// functions to expand the sub-options of encapsulating options
+var subOptionDecoders = map[OptionCode]func([]byte) ([]*Node, error){
+	OptionVendorSpecificInformation:   decodeSubOptions(nil, true),
+	OptionRelayAgentInformation:       decodeSubOptions(relayAgentSubOptionNames, false),
+	OptionVIVendorSpecificInformation: decodeVendorBlocks,
+}
+
+// names of the sub-options of option 82 (RFC3046 and later)
+var relayAgentSubOptionNames = map[byte]string{
+	1:   "Agent Circuit ID",
+	2:   "Agent Remote ID",
+	4:   "DOCSIS Device Class",
+	5:   "Link Selection",
+	6:   "Subscriber ID",
+	7:   "RADIUS Attributes",
+	8:   "Authentication",
+	9:   "Vendor-Specific Information",
+	10:  "Relay Agent Flags",
+	11:  "Server Identifier Override",
+	151: "Virtual Subnet Selection",
+	152: "Virtual Subnet Selection Control",
+}
+
+// build a decoder which expands a series of code, length and value
+// sub-options, naming them from names if it is not nil. if padded is
+// true, codes 0 and 255 are Pad and End as in the options field, which
+// is only the convention of option 43 (RFC2132 section 8.4). elsewhere
+// they are ordinary codes
+func decodeSubOptions(names map[byte]string, padded bool) func([]byte) ([]*Node, error) {
+	return func(v []byte) ([]*Node, error) {
+		var nodes []*Node
+		for i := 0; i < len(v); {
+			code := v[i]
+			if padded && code == byte(OptionPad) {
+				i++
+				continue
+			}
+			if padded && code == byte(OptionEnd) {
+				break
+			}
+			if i+1 >= len(v) || i+2+int(v[i+1]) > len(v) {
+				return nil, errors.Errorf("sub-option %d truncated", code)
+			}
+			data := v[i+2 : i+2+int(v[i+1])]
+			i += 2 + len(data)
+
+			name, ok := names[code]
+			if !ok {
+				name = "Sub-option"
+			}
+			nodes = append(nodes, &Node{
+				Name:  fmt.Sprintf("%s (%d)", name, code),
+				Value: formatBytes(data),
+			})
+		}
+		return nodes, nil
+	}
+}
+
+// expand the enterprise number keyed blocks of option 125 (RFC3925),
+// each of which contains a series of sub-options
+func decodeVendorBlocks(v []byte) ([]*Node, error) {
+	var nodes []*Node
+	for i := 0; i < len(v); {
+		if i+5 > len(v) || i+5+int(v[i+4]) > len(v) {
+			return nil, errors.New("vendor block truncated")
+		}
+		enterprise := binary.BigEndian.Uint32(v[i : i+4])
+		data := v[i+5 : i+5+int(v[i+4])]
+		i += 5 + len(data)
+
+		children, err := decodeSubOptions(nil, false)(data)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &Node{
+			Name:     fmt.Sprintf("Enterprise (%d)", enterprise),
+			Children: children,
+		})
+	}
+	return nodes, nil
+}
+
+// format a value as text if it is printable, otherwise as hex bytes
+func formatBytes(v []byte) string {
+	printable := len(v) > 0
+	for _, c := range v {
+		if c < ' ' || c > '~' {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return strconv.Quote(string(v))
+	}
+
+	hex := make([]string, len(v))
+	for i, c := range v {
+		hex[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(hex, " ")
+}
+
+// format a list of IPv4 addresses
+func formatIPs(v []byte) string {
+	if len(v) == 0 || len(v)%4 != 0 {
+		return formatBytes(v)
+	}
+	ips := make([]string, 0, len(v)/4)
+	for i := 0; i < len(v); i += 4 {
+		ips = append(ips, net.IP(v[i:i+4]).String())
+	}
+	return strings.Join(ips, ", ")
+}
+
+// format a big-endian unsigned integer of 1, 2 or 4 bytes
+func formatUint(v []byte) string {
+	switch len(v) {
+	case 1:
+		return strconv.Itoa(int(v[0]))
+	case 2:
+		return strconv.Itoa(int(binary.BigEndian.Uint16(v)))
+	case 4:
+		return strconv.FormatUint(uint64(binary.BigEndian.Uint32(v)), 10)
+	}
+	return formatBytes(v)
+}
+
+// format a 4-byte number of seconds
+func formatSeconds(v []byte) string {
+	if len(v) != 4 {
+		return formatBytes(v)
+	}
+	if binary.BigEndian.Uint32(v) == 0xffffffff {
+		return "infinite"
+	}
+	return formatDuration(v)
+}
+
+// format a number of seconds which is never infinite
+func formatDuration(v []byte) string {
+	if len(v) != 4 {
+		return formatBytes(v)
+	}
+	s := binary.BigEndian.Uint32(v)
+	return fmt.Sprintf("%ds (%s)", s, time.Duration(s)*time.Second)
+}
+
+func formatMessageType(v []byte) string {
+	if len(v) != 1 {
+		return formatBytes(v)
+	}
+	name, ok := messageTypeNames[MessageType(v[0])]
+	if !ok {
+		name = "unknown"
+	}
+	return fmt.Sprintf("%s (%d)", name, v[0])
+}
+
+// format a classless static route option as destination via router
+func formatRoutes(code OptionCode) func([]byte) string {
+	return func(v []byte) string {
+		routes, err := Options{code: v}.routes(code)
+		if err != nil || len(routes) == 0 {
+			return formatBytes(v)
+		}
+		s := make([]string, len(routes))
+		for i, r := range routes {
+			s[i] = fmt.Sprintf("%s via %s", r.Dest, r.Router)
+		}
+		return strings.Join(s, ", ")
+	}
+}
+
+// format the 6rd option as its prefix, mask length and border relays
+func formatSixRD(v []byte) string {
+	s, err := Options{Option6RD: v}.SixRD()
+	if err != nil {
+		return formatBytes(v)
+	}
+	relays := make([]string, len(s.BorderRelays))
+	for i, br := range s.BorderRelays {
+		relays[i] = br.String()
+	}
+	return fmt.Sprintf("prefix %s/%d, ipv4 mask length %d, border relays %s",
+		s.Prefix, s.PrefixLen, s.IPv4MaskLen, strings.Join(relays, ", "))
+}
+
+// format a list of option codes by name
+func formatCodes(v []byte) string {
+	names := make([]string, len(v))
+	for i, c := range v {
+		names[i] = OptionCode(c).String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,128 @@
+package jdhcp
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMsg(t *testing.T) {
+	got := DecodeMsg(messageParseCases[0].asStruct).String()
+
+	for _, line := range []string{
+		"DHCP message\n",
+		"  xid: 0x00003d1d\n",
+		"  chaddr: 00:0b:82:01:fc:42\n",
+		"  options\n",
+		"    Requested IP Address (50): 0.0.0.0\n",
+		"    DHCP Message Type (53): DHCPDISCOVER (1)\n",
+		"    Parameter Request List (55): Subnet Mask (1), Router (3), Domain Name Server (6), Network Time Protocol Servers (42)\n",
+		"    Client Identifier (61): 01 00 0b 82 01 fc 42\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, got)
+		}
+	}
+}
+
+func TestDecodeSubOptions(t *testing.T) {
+	o := Options{
+		OptionRelayAgentInformation: {0x01, 0x04, 'e', 't', 'h', '0', 0x02, 0x02, 0xab, 0xcd},
+		OptionVIVendorSpecificInformation: {0x00, 0x00, 0x0d, 0xe9, 0x05,
+			0x01, 0x03, 'a', 'b', 'c'},
+		OptionVendorSpecificInformation: {0x01, 0x05, 0x00},
+		OptionTFTPServerAddress:         {10, 0, 0, 5, 10, 0, 0, 6},
+	}
+	nodes := DecodeOptions(o)
+	if len(nodes) != 4 {
+		t.Fatalf("expected 4 options, got %d", len(nodes))
+	}
+
+	// truncated sub-options are shown as bytes
+	if len(nodes[0].Children) != 0 || !strings.Contains(nodes[0].Value, "truncated") {
+		t.Errorf("expected option 43 to be undecodable, got %+v", nodes[0])
+	}
+
+	got := nodes[1].String()
+	expected := "Relay Agent Information (82)\n" +
+		"  Agent Circuit ID (1): \"eth0\"\n" +
+		"  Agent Remote ID (2): ab cd\n"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	got = nodes[2].String()
+	expected = "V-I Vendor-Specific Information (125)\n" +
+		"  Enterprise (3561)\n" +
+		"    Sub-option (1): \"abc\"\n"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	got = nodes[3].String()
+	expected = "TFTP Server Address (150): 10.0.0.5, 10.0.0.6\n"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDecodeSubOptionsPadEnd(t *testing.T) {
+	// Pad and End are only skipped in option 43
+	got := decodeOption(OptionVendorSpecificInformation,
+		[]byte{0x00, 0x01, 0x01, 0xaa, 0xff, 0x02}).String()
+	expected := "Vendor-Specific Information (43)\n" +
+		"  Sub-option (1): aa\n"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	got = decodeOption(OptionRelayAgentInformation,
+		[]byte{0x00, 0x01, 0xaa, 0xff, 0x01, 0xbb}).String()
+	expected = "Relay Agent Information (82)\n" +
+		"  Sub-option (0): aa\n" +
+		"  Sub-option (255): bb\n"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDecodeRoutes(t *testing.T) {
+	got := decodeOption(OptionClasslessStaticRoute,
+		[]byte{8, 10, 192, 168, 0, 1, 0, 192, 168, 0, 254}).String()
+	expected := "Classless Static Route (121): 10.0.0.0/8 via 192.168.0.1, 0.0.0.0/0 via 192.168.0.254\n"
+	if got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestDecodeSixRD(t *testing.T) {
+	o := make(Options)
+	o.SetSixRD(&SixRD{
+		IPv4MaskLen:  8,
+		PrefixLen:    32,
+		Prefix:       net.ParseIP("2001:db8::"),
+		BorderRelays: []net.IP{net.IPv4(192, 0, 2, 1)},
+	})
+	got := decodeOption(Option6RD, o[Option6RD]).String()
+	expected := "6RD (212): prefix 2001:db8::/32, ipv4 mask length 8, border relays 192.0.2.1\n"
+	if got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestOptionCodeString(t *testing.T) {
+	for _, tc := range []struct {
+		code     OptionCode
+		expected string
+	}{
+		{OptionRouter, "Router (3)"},
+		{OptionTFTPServerName, "TFTP Server Name (66)"},
+		{OptionIPv6OnlyPreferred, "IPv6-Only Preferred (108)"},
+		{OptionEnd, "End (255)"},
+		{OptionCode(230), "Unknown (230)"},
+	} {
+		if got := tc.code.String(); got != tc.expected {
+			t.Errorf("expected %q got %q", tc.expected, got)
+		}
+	}
+}
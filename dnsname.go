@@ -0,0 +1,139 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// the longest encoded DNS name (RFC1035 chapter 2.3.4)
+const maxNameLen = 255
+
+// encode name as a sequence of labels (RFC1035 chapter 3.1), followed
+// by the root label unless it is partial. a trailing dot is ignored
+func appendName(b []byte, name string, partial bool) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	start := len(b)
+	if name != "" {
+		for _, l := range strings.Split(name, ".") {
+			if len(l) == 0 || len(l) > maxLabelLen {
+				return nil, errors.Errorf("label %q of %q is not 1 to %d bytes", l, name, maxLabelLen)
+			}
+			b = append(b, byte(len(l)))
+			b = append(b, l...)
+		}
+	}
+	if !partial {
+		b = append(b, 0)
+	}
+	if len(b)-start > maxNameLen {
+		return nil, errors.Errorf("name %q is longer than %d bytes", name, maxNameLen)
+	}
+	return b, nil
+}
+
+// decode the name at the start of data, returning it without a
+// trailing dot, whether it ends without the root label, and its
+// encoded length
+func parseName(data []byte) (name string, partial bool, n int, err error) {
+	var labels []string
+	for {
+		if n == len(data) {
+			partial = true
+			break
+		}
+		l := int(data[n])
+		if l == 0 {
+			n++
+			break
+		}
+		if l > maxLabelLen {
+			return "", false, 0, errors.Errorf("label length %d at offset %d is invalid", l, n)
+		}
+		if n+1+l > len(data) {
+			return "", false, 0, errors.Errorf("label at offset %d truncated", n)
+		}
+		labels = append(labels, string(data[n+1:n+1+l]))
+		n += 1 + l
+	}
+	if n > maxNameLen {
+		return "", false, 0, errors.Errorf("name longer than %d bytes", maxNameLen)
+	}
+	return strings.Join(labels, "."), partial, n, nil
+}
+
+// encode name as labels, compressed with pointers (RFC1035 chapter
+// 4.1.4) to the names already in b. offsets maps each name and suffix
+// already encoded to its offset in b, and is updated with those of name
+func appendCompressedName(b []byte, name string, offsets map[string]int) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if _, err := appendName(nil, name, false); err != nil {
+		return nil, err
+	}
+
+	for name != "" {
+		key := strings.ToLower(name)
+		if off, ok := offsets[key]; ok {
+			return append(b, 0xc0|byte(off>>8), byte(off)), nil
+		}
+		// pointers only have 14 bits
+		if len(b) <= 0x3fff {
+			offsets[key] = len(b)
+		}
+
+		l := name
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			l, name = name[:i], name[i+1:]
+		} else {
+			name = ""
+		}
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, 0), nil
+}
+
+// decode the name at offset off of data, following compression
+// pointers, which must point before themselves so that they cannot
+// loop. returns the name without a trailing dot and the offset after it
+func parseCompressedName(data []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	length := 0
+	for i := off; ; {
+		if i >= len(data) {
+			return "", 0, errors.Errorf("name at offset %d truncated", off)
+		}
+		l := int(data[i])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = i + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case l&0xc0 == 0xc0:
+			if i+1 >= len(data) {
+				return "", 0, errors.Errorf("pointer at offset %d truncated", i)
+			}
+			ptr := (l&0x3f)<<8 | int(data[i+1])
+			if ptr >= i {
+				return "", 0, errors.Errorf("pointer at offset %d does not point backwards", i)
+			}
+			if next < 0 {
+				next = i + 2
+			}
+			i = ptr
+		case l > maxLabelLen:
+			return "", 0, errors.Errorf("label length %d at offset %d is invalid", l, i)
+		default:
+			if i+1+l > len(data) {
+				return "", 0, errors.Errorf("label at offset %d truncated", i)
+			}
+			length += 1 + l
+			if length+1 > maxNameLen {
+				return "", 0, errors.Errorf("name at offset %d longer than %d bytes", off, maxNameLen)
+			}
+			labels = append(labels, string(data[i+1:i+1+l]))
+			i += 1 + l
+		}
+	}
+}
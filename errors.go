@@ -0,0 +1,37 @@
+package jdhcp
+
+import (
+	"fmt"
+)
+
+// a ParseError describes where parsing a message failed. Err is the
+// underlying problem, which is often one of the sentinel errors such
+// as ErrShortRead or ErrLimitExceeded. it can be found with errors.Is,
+// or with errors.Cause from github.com/pkg/errors
+type ParseError struct {
+	Offset int    // offset of the problem in the message
+	Field  string // the field being parsed, such as "hlen" or "options"
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse %s at offset %d: %s", e.Field, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+func (e *ParseError) Cause() error  { return e.Err }
+
+// an OptionError is returned by the accessors of Options when the
+// value of an option cannot be decoded or encoded. an option which is
+// not present is reported by returning ErrOptionNotPresent by itself
+type OptionError struct {
+	Code OptionCode
+	Err  error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option %d: %s", e.Code, e.Err)
+}
+
+func (e *OptionError) Unwrap() error { return e.Err }
+func (e *OptionError) Cause() error  { return e.Err }
@@ -0,0 +1,62 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	good := messageParseCases[0].asBytes
+	badCookie := append([]byte(nil), good...)
+	badCookie[237] = 0
+
+	for i, tc := range []struct {
+		data   []byte
+		limits ParseLimits
+		field  string
+		offset int
+		cause  error
+	}{
+		{good[:100], ParseLimits{}, "header", 100, ErrShortRead},
+		{good, ParseLimits{MaxSize: 250}, "message", 0, ErrLimitExceeded},
+		{badCookie, ParseLimits{}, "cookie", 236, nil},
+		// the third option is the parameter request list
+		{good, ParseLimits{MaxOptions: 2}, "options", 249, ErrLimitExceeded},
+	} {
+		_, err := ParseMsgLimited(tc.data, tc.limits)
+
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("case %d: expected a ParseError, got %v", i, err)
+			continue
+		}
+		if pe.Field != tc.field || pe.Offset != tc.offset {
+			t.Errorf("case %d: expected %s at %d, got %s at %d",
+				i, tc.field, tc.offset, pe.Field, pe.Offset)
+		}
+		if tc.cause != nil && !errors.Is(err, tc.cause) {
+			t.Errorf("case %d: expected %v to wrap %v", i, err, tc.cause)
+		}
+		if tc.cause != nil && errors.Cause(err) != tc.cause {
+			t.Errorf("case %d: expected cause %v, got %v", i, tc.cause, errors.Cause(err))
+		}
+	}
+}
+
+func TestOptionError(t *testing.T) {
+	o := Options{OptionTFTPServerAddress: {10, 0}}
+
+	_, err := o.TFTPServers()
+	var oe *OptionError
+	if !errors.As(err, &oe) || oe.Code != OptionTFTPServerAddress {
+		t.Fatalf("expected an OptionError for option 150, got %v", err)
+	}
+	if !errors.Is(err, ErrShortRead) {
+		t.Errorf("expected %v to wrap ErrShortRead", err)
+	}
+
+	_, err = o.CAPWAPAccessControllers()
+	if err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// the filter sees the UDP header in front of the DHCP message
+const filterUDPHeaderLen = 8
+
+// attach a socket filter to conn, which must be a UDP socket, so that
+// the kernel drops datagrams which are obviously not DHCP messages
+// before they are read. this protects the Server from floods of junk
+// sent to its port, such as when it is used with StartConn
+func AttachSocketFilter(conn net.PacketConn) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.Errorf("cannot attach a socket filter to %T", conn)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "attach socket filter")
+	}
+
+	// drop anything shorter than a DHCP header or without the
+	// magic cookie, and accept up to the largest UDP datagram
+	prog := []syscall.SockFilter{
+		*syscall.LsfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_LEN, 0),
+		*syscall.LsfJump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, filterUDPHeaderLen+240, 0, 3),
+		*syscall.LsfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, filterUDPHeaderLen+236),
+		*syscall.LsfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, int(Cookie), 0, 1),
+		*syscall.LsfStmt(syscall.BPF_RET|syscall.BPF_K, 0xffff),
+		*syscall.LsfStmt(syscall.BPF_RET|syscall.BPF_K, 0),
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.AttachLsf(int(fd), prog)
+	})
+	if err != nil {
+		return errors.Wrap(err, "attach socket filter")
+	}
+	if serr != nil {
+		return errors.Wrap(os.NewSyscallError("setsockopt", serr), "attach socket filter")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package jdhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAttachSocketFilter(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer conn.Close()
+	err = AttachSocketFilter(conn)
+	if err != nil {
+		t.Fatalf("cannot attach filter: %s", err)
+	}
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("cannot dial: %s", err)
+	}
+	defer client.Close()
+
+	msg := NewMsg()
+	msg.Hlen = 6
+	msg.Xid = 0x12345678
+	good, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	badCookie := append([]byte(nil), good...)
+	badCookie[236] ^= 0xff
+
+	for _, b := range [][]byte{[]byte("not dhcp"), good[:239], badCookie, good} {
+		if _, err := client.Write(b); err != nil {
+			t.Fatalf("cannot send: %s", err)
+		}
+	}
+
+	// only the valid message gets through
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("cannot read: %s", err)
+	}
+	got, err := ParseMsg(buf[:n])
+	if err != nil || got.Xid != msg.Xid {
+		t.Errorf("expected the valid message got %d bytes (%v)", n, err)
+	}
+}
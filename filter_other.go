@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// attach a socket filter to conn, which must be a UDP socket, so that
+// the kernel drops datagrams which are obviously not DHCP messages
+// before they are read. this is only supported on linux
+func AttachSocketFilter(conn net.PacketConn) error {
+	return errors.New("socket filters are not supported on this platform")
+}
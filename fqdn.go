@@ -0,0 +1,75 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// flags of the Client FQDN option (RFC4702 chapter 2.1)
+const (
+	FQDNServerUpdate byte = 0x01 // S, the server should update the A RR
+	FQDNOverride     byte = 0x02 // O, the server overrode the S flag of the client
+	FQDNEncoded      byte = 0x04 // E, the name is in the canonical wire format
+	FQDNNoUpdate     byte = 0x08 // N, the server should not update any RR
+)
+
+// a ClientFQDN is the content of option 81, with which a client and
+// server agree on who registers the name of the client in DNS
+type ClientFQDN struct {
+	Flags  byte
+	RCODE1 byte // deprecated, 0 from clients and 255 from servers
+	RCODE2 byte // deprecated, as RCODE1
+	Name   string
+	// Name is not fully qualified, and the server is to complete it
+	Partial bool
+}
+
+// option 81. the name is decoded from the canonical wire format if
+// the E flag is set, or otherwise from the deprecated ASCII format,
+// where a name is fully qualified if it ends with a dot
+func (o Options) ClientFQDN() (*ClientFQDN, error) {
+	v, ok := o[OptionClientFQDN]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 3 {
+		return nil, &OptionError{OptionClientFQDN, ErrShortRead}
+	}
+
+	f := &ClientFQDN{Flags: v[0], RCODE1: v[1], RCODE2: v[2]}
+	if f.Flags&FQDNEncoded == 0 {
+		name := strings.TrimRight(string(v[3:]), "\x00")
+		f.Name = strings.TrimSuffix(name, ".")
+		f.Partial = name == f.Name
+		return f, nil
+	}
+
+	name, partial, n, err := parseName(v[3:])
+	if err != nil {
+		return nil, &OptionError{OptionClientFQDN, err}
+	}
+	if 3+n != len(v) {
+		return nil, &OptionError{OptionClientFQDN, errors.Errorf("%d bytes after the name", len(v)-3-n)}
+	}
+	f.Name, f.Partial = name, partial
+	return f, nil
+}
+
+// set option 81. the name is always encoded in the canonical wire
+// format, so the E flag is set. the S flag must not be set with N
+func (o Options) SetClientFQDN(f *ClientFQDN) error {
+	flags := f.Flags | FQDNEncoded
+	if flags&FQDNNoUpdate != 0 && flags&FQDNServerUpdate != 0 {
+		return &OptionError{OptionClientFQDN, errors.New("flags S and N are both set")}
+	}
+	if flags&^(FQDNServerUpdate|FQDNOverride|FQDNEncoded|FQDNNoUpdate) != 0 {
+		return &OptionError{OptionClientFQDN, errors.Errorf("unknown flags 0x%02x", flags)}
+	}
+
+	v, err := appendName([]byte{flags, f.RCODE1, f.RCODE2}, f.Name, f.Partial)
+	if err != nil {
+		return &OptionError{OptionClientFQDN, err}
+	}
+	o[OptionClientFQDN] = v
+	return nil
+}
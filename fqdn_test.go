@@ -0,0 +1,54 @@
+package jdhcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClientFQDN(t *testing.T) {
+	o := make(Options)
+	err := o.SetClientFQDN(&ClientFQDN{Flags: FQDNServerUpdate, Name: "host.example.com."})
+	if err != nil {
+		t.Fatalf("o.SetClientFQDN() returned error: %s", err)
+	}
+	want := append([]byte{0x05, 0, 0, 4}, "host\x07example\x03com\x00"...)
+	if !bytes.Equal(o[OptionClientFQDN], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionClientFQDN])
+	}
+
+	for i, tc := range []struct {
+		value   []byte
+		name    string
+		partial bool
+	}{
+		// 0 canonical wire format
+		{want, "host.example.com", false},
+		// 1 partial name in the canonical wire format
+		{[]byte{0x04, 0, 0, 4, 'h', 'o', 's', 't'}, "host", true},
+		// 2 ascii format
+		{append([]byte{0x01, 0, 0}, "host.example.com."...), "host.example.com", false},
+		// 3 partial name in the ascii format
+		{append([]byte{0x00, 0, 0}, "host\x00"...), "host", true},
+	} {
+		o[OptionClientFQDN] = tc.value
+		f, err := o.ClientFQDN()
+		if err != nil {
+			t.Errorf("%d: o.ClientFQDN() returned error: %s", i, err)
+			continue
+		}
+		if f.Name != tc.name || f.Partial != tc.partial || f.Flags != tc.value[0] {
+			t.Errorf("%d: unexpected fqdn %+v", i, f)
+		}
+	}
+
+	o[OptionClientFQDN] = []byte{0x04, 0, 0, 5, 'h', 'o'}
+	if _, err := o.ClientFQDN(); err == nil {
+		t.Errorf("expected error for a truncated name")
+	}
+	if err := o.SetClientFQDN(&ClientFQDN{Flags: FQDNServerUpdate | FQDNNoUpdate}); err == nil {
+		t.Errorf("expected error for flags S and N")
+	}
+	if err := o.SetClientFQDN(&ClientFQDN{Name: "a..b"}); err == nil {
+		t.Errorf("expected error for an empty label")
+	}
+}
@@ -0,0 +1,161 @@
+package jdhcp
+
+import (
+	"context"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Hooks are programs run by a Server when a lease changes, in the
+// manner of the on commit, on release and on expiry statements of
+// dhcpd. each program is run without arguments, with the details of
+// the lease in these environment variables:
+//
+//	JDHCP_EVENT       commit, release or expiry
+//	JDHCP_IP          the address of the lease
+//	JDHCP_MAC         the hardware address of the client
+//	JDHCP_CLIENT_ID   option 61 of the client as hex, if it sent one
+//	JDHCP_HOSTNAME    option 12 of the client, if it sent one
+//	JDHCP_LEASE_TIME  the lease time in seconds, on commit only
+//
+// jdhcp keeps no leases, so it cannot tell when one expires. the
+// expiry program is run when the handler calls Server.RunExpiry
+type Hooks struct {
+	Commit  string // run after a DHCPACK is sent
+	Release string // run when a DHCPRELEASE is received
+	Expiry  string // run by Server.RunExpiry
+
+	Timeout    time.Duration // after which a program is killed, 10s if zero
+	MaxRunning int           // programs running at once, 4 if zero
+}
+
+// the programs started by the hookRunners of a Server, which Stop
+// waits for. no more are started once it is stopped
+type hookGroup struct {
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// count another program, unless the group is stopped
+func (g *hookGroup) add() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+// wait for the programs running now, which are killed at their
+// timeout, and start no more
+func (g *hookGroup) stop() {
+	g.mu.Lock()
+	g.stopped = true
+	g.mu.Unlock()
+	g.wg.Wait()
+}
+
+// runs the programs of Hooks, skipping them when too many are running
+type hookRunner struct {
+	hooks Hooks
+	log   *log.Logger
+	sem   chan struct{}
+	group *hookGroup
+}
+
+func newHookRunner(h Hooks, lg *log.Logger, g *hookGroup) *hookRunner {
+	if h.Timeout == 0 {
+		h.Timeout = 10 * time.Second
+	}
+	if h.MaxRunning == 0 {
+		h.MaxRunning = 4
+	}
+	return &hookRunner{hooks: h, log: lg, sem: make(chan struct{}, h.MaxRunning), group: g}
+}
+
+// run the hook for a commit of the lease in res, sent in reply to req
+func (r *hookRunner) commit(req, res *Msg) {
+	env := hookEnv("commit", res.Yiaddr, req)
+	if d, err := res.IPAddressLeaseTime(); err == nil {
+		secs := int64(infiniteSeconds)
+		if d != InfiniteLease {
+			secs = int64(d / time.Second)
+		}
+		env = append(env, "JDHCP_LEASE_TIME="+strconv.FormatInt(secs, 10))
+	}
+	r.run(r.hooks.Commit, env)
+}
+
+// run the hook for the release of its lease by the sender of req
+func (r *hookRunner) release(req *Msg) {
+	r.run(r.hooks.Release, hookEnv("release", req.Ciaddr, req))
+}
+
+// run the hook for the expiry of the lease of ip to hw
+func (r *hookRunner) expiry(ip net.IP, hw net.HardwareAddr) {
+	r.run(r.hooks.Expiry, leaseEnv("expiry", ip, hw))
+}
+
+// the environment of a hook, copied out of req as it is recycled
+// before the program runs
+func hookEnv(event string, ip net.IP, req *Msg) []string {
+	env := leaseEnv(event, ip, req.Chaddr)
+	if id, ok := req.Options[OptionClientID]; ok {
+		env = append(env, "JDHCP_CLIENT_ID="+hex.EncodeToString(id))
+	}
+	if name, err := req.HostName(); err == nil {
+		env = append(env, "JDHCP_HOSTNAME="+name)
+	}
+	return env
+}
+
+// the environment of a hook which only knows the lease
+func leaseEnv(event string, ip net.IP, hw net.HardwareAddr) []string {
+	return append(os.Environ(),
+		"JDHCP_EVENT="+event,
+		"JDHCP_IP="+ip.String(),
+		"JDHCP_MAC="+hw.String(),
+	)
+}
+
+// start program with env in the background, unless it is empty, too
+// many programs are running already or the Server has stopped
+func (r *hookRunner) run(program string, env []string) {
+	if program == "" {
+		return
+	}
+	select {
+	case r.sem <- struct{}{}:
+	default:
+		r.log.Printf("skipping hook %s, %d already running", program, cap(r.sem))
+		return
+	}
+	if !r.group.add() {
+		<-r.sem
+		r.log.Printf("skipping hook %s, server stopped", program)
+		return
+	}
+
+	go func() {
+		defer r.group.wg.Done()
+		defer func() { <-r.sem }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.hooks.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, program)
+		cmd.Env = env
+		// the output is not collected, as a child of the program
+		// could then keep it running past the timeout
+		if err := cmd.Run(); err != nil {
+			r.log.Printf("hook %s failed: %s", program, err)
+		}
+	}()
+}
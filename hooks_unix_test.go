@@ -0,0 +1,141 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package jdhcp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// write a shell script to dir which saves its environment to out
+func writeHook(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	if err != nil {
+		t.Fatalf("cannot write hook: %s", err)
+	}
+	return path
+}
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "env")
+	r := newHookRunner(Hooks{
+		Commit:  writeHook(t, dir, "commit", "env > "+out),
+		Release: writeHook(t, dir, "release", "env > "+out),
+		Expiry:  writeHook(t, dir, "expiry", "env > "+out),
+	}, testLogg, new(hookGroup))
+
+	req := NewMsg()
+	req.Chaddr = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+	req.Options[OptionHostName] = []byte("printer")
+	res := NewMsg()
+	res.Yiaddr = net.IPv4(10, 0, 0, 7)
+	res.SetIPAddressLeaseTime(time.Hour)
+
+	r.commit(req, res)
+	r.group.wg.Wait()
+	env, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("commit hook did not run: %s", err)
+	}
+	for _, v := range []string{
+		"JDHCP_EVENT=commit",
+		"JDHCP_IP=10.0.0.7",
+		"JDHCP_MAC=00:11:22:33:44:55",
+		"JDHCP_HOSTNAME=printer",
+		"JDHCP_LEASE_TIME=3600",
+	} {
+		if !strings.Contains(string(env), v+"\n") {
+			t.Errorf("expected %s in the environment of the hook", v)
+		}
+	}
+
+	req.Ciaddr = net.IPv4(10, 0, 0, 7)
+	r.release(req)
+	r.group.wg.Wait()
+	env, err = ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("release hook did not run: %s", err)
+	}
+	if !strings.Contains(string(env), "JDHCP_EVENT=release\n") || strings.Contains(string(env), "JDHCP_LEASE_TIME") {
+		t.Errorf("unexpected environment of release hook:\n%s", env)
+	}
+
+	r.expiry(net.IPv4(10, 0, 0, 8), req.Chaddr)
+	r.group.wg.Wait()
+	env, err = ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expiry hook did not run: %s", err)
+	}
+	for _, v := range []string{
+		"JDHCP_EVENT=expiry",
+		"JDHCP_IP=10.0.0.8",
+		"JDHCP_MAC=00:11:22:33:44:55",
+	} {
+		if !strings.Contains(string(env), v+"\n") {
+			t.Errorf("expected %s in the environment of the expiry hook", v)
+		}
+	}
+}
+
+func TestHooksLimits(t *testing.T) {
+	dir := t.TempDir()
+	r := newHookRunner(Hooks{
+		Commit:     writeHook(t, dir, "commit", "sleep 5"),
+		Timeout:    50 * time.Millisecond,
+		MaxRunning: 1,
+	}, testLogg, new(hookGroup))
+
+	// the second hook is skipped while the first runs, and the
+	// first is killed at the timeout rather than running for 5s
+	start := time.Now()
+	r.commit(NewMsg(), NewMsg())
+	r.commit(NewMsg(), NewMsg())
+	r.group.wg.Wait()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected hook to be killed after its timeout, took %s", d)
+	}
+	if len(r.sem) != 0 {
+		t.Errorf("expected no hooks running got %d", len(r.sem))
+	}
+}
+
+func TestServerStopWaitsForHooks(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "done")
+	serv := NewServer(WithLogger(testLogg))
+	serv.SetHooks(Hooks{Expiry: writeHook(t, dir, "expiry", "sleep 0.2; touch "+out)})
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+
+	hw := net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+	serv.RunExpiry(net.IPv4(10, 0, 0, 8), hw)
+	err = serv.Stop()
+	if err != nil {
+		t.Fatalf("could not stop server: %s", err)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("expected Stop to wait for the hook: %s", err)
+	}
+
+	// no more hooks are started once the Server is stopped
+	os.Remove(out)
+	serv.RunExpiry(net.IPv4(10, 0, 0, 8), hw)
+	serv.running.wg.Wait()
+	if _, err := os.Stat(out); err == nil {
+		t.Errorf("expected no hook to run after Stop")
+	}
+}
@@ -0,0 +1,116 @@
+package jdhcp
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// the longest DNS label (RFC1035 chapter 2.3.4)
+const maxLabelLen = 63
+
+// convert a host name sent by a client (option 12) into a valid DNS
+// label, suitable for recording with a lease or registering in DNS.
+// only the first label of the name is kept, it is lowercased, runs of
+// invalid characters become a single hyphen and it is cut to 63
+// characters. the result is empty if nothing usable is left
+func SanitizeHostname(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return trimLabel(b.String(), maxLabelLen)
+}
+
+// cut a label to n characters, without leaving a trailing hyphen
+func trimLabel(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.TrimRight(s, "-")
+}
+
+// Hostnames gives each client a unique host name, so that two
+// clients asking for the same name do not collide in DNS or in lease
+// records. clients are identified by a string chosen by the caller,
+// such as their client identifier or hardware address.
+// it is safe for concurrent use
+type Hostnames struct {
+	mu     sync.Mutex
+	byName map[string]string        // canonical name to client
+	byID   map[string]hostnameClaim // client to its name
+}
+
+type hostnameClaim struct {
+	base, name string // sanitized name asked for and name given
+}
+
+// create an empty set of Hostnames
+func NewHostnames() *Hostnames {
+	return &Hostnames{
+		byName: make(map[string]string),
+		byID:   make(map[string]hostnameClaim),
+	}
+}
+
+// give the client id the sanitized form of name, or if another client
+// already has that, the first of name-2, name-3 and so on which is
+// free. the canonical name is returned, which is empty if name has
+// nothing usable in it. a client asking for the same name again keeps
+// the name it was given, otherwise its old name is released
+func (h *Hostnames) Claim(id, name string) string {
+	base := SanitizeHostname(name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old, ok := h.byID[id]; ok {
+		if old.base == base {
+			return old.name
+		}
+		delete(h.byName, old.name)
+		delete(h.byID, id)
+	}
+	if base == "" {
+		return ""
+	}
+
+	canonical := base
+	for n := 2; h.byName[canonical] != ""; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		canonical = trimLabel(base, maxLabelLen-len(suffix)) + suffix
+	}
+
+	h.byName[canonical] = id
+	h.byID[id] = hostnameClaim{base, canonical}
+	return canonical
+}
+
+// get the canonical name of a client
+func (h *Hostnames) Lookup(id string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.byID[id]
+	return c.name, ok
+}
+
+// release the name of a client, such as when its lease expires
+func (h *Hostnames) Release(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c, ok := h.byID[id]; ok {
+		delete(h.byName, c.name)
+		delete(h.byID, id)
+	}
+}
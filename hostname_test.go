@@ -0,0 +1,63 @@
+package jdhcp
+
+import (
+	"strings"
+	"testing"
+)
+
+var sanitizeHostnameCases = []struct {
+	in, out string
+}{
+	{"printer", "printer"},
+	{"Johns-iPhone", "johns-iphone"},
+	{"laptop.example.com", "laptop"},
+	{"John's MacBook Pro", "john-s-macbook-pro"},
+	{"--odd__name--", "odd-name"},
+	{"bad\x00name", "bad-name"},
+	{"...", ""},
+	{strings.Repeat("a", 70), strings.Repeat("a", 63)},
+	{strings.Repeat("a", 62) + "-b", strings.Repeat("a", 62)},
+}
+
+func TestSanitizeHostname(t *testing.T) {
+	for i, tc := range sanitizeHostnameCases {
+		got := SanitizeHostname(tc.in)
+		if got != tc.out {
+			t.Errorf("case %d expected %q got %q", i, tc.out, got)
+		}
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	h := NewHostnames()
+
+	for i, tc := range []struct {
+		id, name, expected string
+	}{
+		{"a", "Printer", "printer"},
+		{"b", "printer", "printer-2"},
+		{"c", "PRINTER", "printer-3"},
+		{"b", "printer", "printer-2"}, // asking again keeps the name
+		{"a", "scanner", "scanner"},   // old name is released
+		{"d", "printer", "printer"},
+		{"e", "", ""},
+		{"f", strings.Repeat("x", 63), strings.Repeat("x", 63)},
+		{"g", strings.Repeat("x", 63), strings.Repeat("x", 61) + "-2"},
+	} {
+		got := h.Claim(tc.id, tc.name)
+		if got != tc.expected {
+			t.Errorf("case %d expected %q got %q", i, tc.expected, got)
+		}
+	}
+
+	h.Release("b")
+	if _, ok := h.Lookup("b"); ok {
+		t.Error("expected released client to have no name")
+	}
+	if got := h.Claim("h", "printer"); got != "printer-2" {
+		t.Errorf("expected released name to be reused, got %q", got)
+	}
+	if got, _ := h.Lookup("c"); got != "printer-3" {
+		t.Errorf("expected printer-3, got %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package jdhcp
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// open a UDP socket on port which sends its datagrams out of the
+// named interface, including those sent to the limited broadcast
+// address 255.255.255.255. without this the kernel picks the
+// interface for a broadcast by itself, which is usually the one with
+// the default route. the result can be passed to Server.StartConn to
+// serve a single interface.
+//
+// how the interface is chosen depends on the OS: SO_BINDTODEVICE on
+// Linux, IP_BOUND_IF on macOS, IP_MULTICAST_IF on the BSDs and
+// IP_UNICAST_IF on Windows. on Linux this needs CAP_NET_RAW, and
+// the socket then only receives from that interface as well
+func ListenInterface(name string, port int) (net.PacketConn, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "find interface")
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				serr = bindToInterface(fd, iface)
+			})
+			if err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	conn, err := lc.ListenPacket(context.Background(), "udp4",
+		net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "listen on %s", name)
+	}
+	return conn, nil
+}
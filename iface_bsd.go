@@ -0,0 +1,36 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+// +build dragonfly freebsd netbsd openbsd
+
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// the BSDs have no option to bind a socket to an interface, but send
+// limited broadcasts out of the interface chosen for multicast
+func bindToInterface(fd uintptr, iface *net.Interface) error {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return errors.Wrap(err, "get interface addresses")
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		var ip [4]byte
+		copy(ip[:], ipnet.IP.To4())
+
+		err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+		if err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+		err = syscall.SetsockoptInet4Addr(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, ip)
+		return os.NewSyscallError("setsockopt", err)
+	}
+	return errors.Errorf("interface %s has no IPv4 address", iface.Name)
+}
@@ -0,0 +1,16 @@
+package jdhcp
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+func bindToInterface(fd uintptr, iface *net.Interface) error {
+	err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	if err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_BOUND_IF, iface.Index)
+	return os.NewSyscallError("setsockopt", err)
+}
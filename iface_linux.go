@@ -0,0 +1,18 @@
+package jdhcp
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+func bindToInterface(fd uintptr, iface *net.Interface) error {
+	// more than one socket can then use the same port on
+	// different interfaces
+	err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	if err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface.Name)
+	return os.NewSyscallError("setsockopt", err)
+}
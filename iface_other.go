@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+func bindToInterface(fd uintptr, iface *net.Interface) error {
+	return errors.New("binding to an interface is not supported on this platform")
+}
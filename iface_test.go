@@ -0,0 +1,35 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestListenInterface(t *testing.T) {
+	_, err := ListenInterface("jdhcp-missing0", 0)
+	if err == nil {
+		t.Error("expected an error for a missing interface")
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("could not list interfaces: %s", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		conn, err := ListenInterface(iface.Name, 0)
+		if errors.Is(err, os.ErrPermission) {
+			t.Skipf("not allowed to bind to %s: %s", iface.Name, err)
+		}
+		if err != nil {
+			t.Fatalf("could not listen on %s: %s", iface.Name, err)
+		}
+		conn.Close()
+		return
+	}
+	t.Skip("no loopback interface")
+}
@@ -0,0 +1,20 @@
+package jdhcp
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+)
+
+// not defined by the syscall package
+const ipUnicastIf = 31
+
+func bindToInterface(fd uintptr, iface *net.Interface) error {
+	// the interface index is given in network byte order
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], uint32(iface.Index))
+	err := syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, ipUnicastIf,
+		int(binary.LittleEndian.Uint32(idx[:])))
+	return os.NewSyscallError("setsockopt", err)
+}
@@ -0,0 +1,212 @@
+//go:build interop
+// +build interop
+
+package jdhcp
+
+// The interop tests run widely used DHCP clients against a Server
+// to catch wire format assumptions that the unit tests never will.
+// They are only built with the interop tag, and need to be run as
+// root on a machine with the clients installed:
+//
+//   go test -tags interop -run Interop
+//
+// by default the clients are run in a network namespace connected
+// to the Server by a testLink. to use a real interface instead, set
+// JDHCP_INTEROP_IFACE to its name and JDHCP_INTEROP_ADDR to its
+// address, which must be a /24. there should be nothing else serving
+// DHCP on that link. clients that are not installed are skipped.
+//
+// jdhcp does not have a client yet, so running it against other
+// servers is not covered here.
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// an external client and how to run it on an interface, with the
+// names of the variables it passes to its script
+type interopClient struct {
+	name     string
+	bin      string
+	args     func(iface, script, dir string) []string
+	addrVar  string
+	maskVar  string
+	routeVar string
+	dnsVar   string
+}
+
+var interopClients = []interopClient{
+	{
+		name: "udhcpc",
+		bin:  "udhcpc",
+		args: func(iface, script, dir string) []string {
+			return []string{"-f", "-q", "-n", "-t", "3", "-T", "1",
+				"-i", iface, "-s", script}
+		},
+		addrVar:  "ip",
+		maskVar:  "subnet",
+		routeVar: "router",
+		dnsVar:   "dns",
+	},
+	{
+		name: "dhclient",
+		bin:  "dhclient",
+		args: func(iface, script, dir string) []string {
+			return []string{"-1", "-d", "-sf", script,
+				"-lf", filepath.Join(dir, "dhclient.leases"),
+				"-pf", filepath.Join(dir, "dhclient.pid"), iface}
+		},
+		addrVar:  "new_ip_address",
+		maskVar:  "new_subnet_mask",
+		routeVar: "new_routers",
+		dnsVar:   "new_domain_name_servers",
+	},
+}
+
+func TestInteropClients(t *testing.T) {
+	// the command prefix used to run a client in its namespace
+	var prefix []string
+	iface := os.Getenv("JDHCP_INTEROP_IFACE")
+	addr := net.ParseIP(os.Getenv("JDHCP_INTEROP_ADDR")).To4()
+	start := func(fn func()) { fn() }
+	if iface == "" || addr == nil {
+		link := newTestLink(t)
+		prefix = []string{"ip", "netns", "exec", link.ClientNS}
+		iface = link.ClientIface
+		addr = link.ServerAddr
+		start = func(fn func()) { inNetns(t, link.ServerNS, fn) }
+	}
+	offer := net.IPv4(addr[0], addr[1], addr[2], 100).To4()
+
+	serv := NewServer(WithLogger(testLogg))
+	serv.RegisterCallback(interopCallback(addr, offer))
+	start(func() {
+		err := serv.Start()
+		if err != nil {
+			t.Fatalf("could not start server: %s", err)
+		}
+	})
+	defer serv.Stop()
+
+	for _, c := range interopClients {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			bin, err := exec.LookPath(c.bin)
+			if err != nil {
+				t.Skipf("%s is not installed", c.bin)
+			}
+
+			env := runInteropClient(t, append(prefix, bin), c, iface)
+			for k, v := range map[string]string{
+				c.addrVar:  offer.String(),
+				c.maskVar:  "255.255.255.0",
+				c.routeVar: addr.String(),
+				c.dnsVar:   addr.String(),
+			} {
+				if strings.TrimSpace(env[k]) != v {
+					t.Errorf("expected %s=%s got %q", k, v, env[k])
+				}
+			}
+		})
+	}
+}
+
+// build a callback that hands out offer to any client, with
+// addr as the server identifier, router and DNS server
+func interopCallback(addr, offer net.IP) MsgCallback {
+	return func(req Msg) *Msg {
+		t, err := req.DHCPMessageType()
+		if err != nil {
+			return nil
+		}
+
+		res := NewMsg()
+		switch t {
+		case Discover:
+			res.Options[OptionDHCPMessageType] = []byte{byte(Offer)}
+		case Request:
+			res.Options[OptionDHCPMessageType] = []byte{byte(ACK)}
+		default:
+			return nil
+		}
+
+		res.Op = 2
+		res.Htype = req.Htype
+		res.Hlen = req.Hlen
+		res.Xid = req.Xid
+		res.Flags = req.Flags
+		res.Giaddr = req.Giaddr
+		res.Chaddr = req.Chaddr
+		res.Yiaddr = offer
+
+		lease := make([]byte, 4)
+		binary.BigEndian.PutUint32(lease, 3600)
+
+		res.Options[OptionServerIdentifier] = addr
+		res.Options[OptionSubnetMask] = net.IPv4Mask(255, 255, 255, 0)
+		res.Options[OptionCode(3)] = addr // router
+		res.Options[OptionCode(6)] = addr // dns servers
+		res.Options[OptionCode(51)] = lease
+		return res
+	}
+}
+
+// run an external client until it reports a lease (or times out),
+// returning the environment passed to its script when it was bound.
+// cmd is the client binary, possibly preceded by a command to run it
+func runInteropClient(t *testing.T, cmd []string, c interopClient, iface string) map[string]string {
+	dir, err := ioutil.TempDir("", "jdhcp-interop")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "env")
+	script := filepath.Join(dir, "script")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nenv > "+out+".tmp && mv "+out+".tmp "+out+"\n"), 0755)
+	if err != nil {
+		t.Fatalf("could not write client script: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	args := append(cmd[1:], c.args(iface, script, dir)...)
+	proc := exec.CommandContext(ctx, cmd[0], args...)
+	err = proc.Start()
+	if err != nil {
+		t.Fatalf("could not start %s: %s", c.name, err)
+	}
+	defer proc.Wait()
+	defer proc.Process.Kill()
+
+	// the script is called for every state change, so wait until
+	// one of the calls includes an address
+	for ctx.Err() == nil {
+		data, err := ioutil.ReadFile(out)
+		if err == nil {
+			env := make(map[string]string)
+			for _, l := range strings.Split(string(data), "\n") {
+				if kv := strings.SplitN(l, "=", 2); len(kv) == 2 {
+					env[kv[0]] = kv[1]
+				}
+			}
+			if env[c.addrVar] != "" {
+				return env
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatalf("%s did not get a lease", c.name)
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package jdhcptest provides utilities for testing code which
+// uses jdhcp, such as servers built on top of it.
+package jdhcptest
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Faults describes how often each kind of fault is injected into
+// the packets passing in one direction through a FaultConn. each
+// probability is in the range 0 to 1, and they are rolled independently
+type Faults struct {
+	Drop      float64 // packet is silently discarded
+	Duplicate float64 // packet is delivered twice
+	Reorder   float64 // packet is held back until after the next one
+	Truncate  float64 // packet is cut to a random shorter length
+	Delay     float64 // packet is held for up to MaxDelay
+	MaxDelay  time.Duration
+}
+
+// FaultCounts records how many of each fault a FaultConn has injected
+type FaultCounts struct {
+	Dropped, Duplicated, Reordered, Truncated, Delayed int
+}
+
+// a FaultConn wraps a net.PacketConn, injecting faults into the
+// packets it reads (In) and writes (Out). the faults are chosen by
+// a random source created from a seed, so a sequence of packets
+// is always affected in the same way when the seed is the same.
+//
+// In and Out should be set before the FaultConn is used. a written
+// packet which is still held back for reordering when the FaultConn
+// is closed is never sent.
+type FaultConn struct {
+	net.PacketConn
+	In, Out Faults
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	counts  FaultCounts
+	pending []packet // packets waiting to be read
+	held    *packet  // write held back for reordering
+}
+
+// a packet read from or written to the wrapped conn
+type packet struct {
+	data []byte
+	addr net.Addr
+}
+
+// wrap conn in a FaultConn using seed for its random source.
+// no faults are injected until In or Out are set
+func NewFaultConn(conn net.PacketConn, seed int64) *FaultConn {
+	return &FaultConn{
+		PacketConn: conn,
+		rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// get the number of faults injected so far
+func (c *FaultConn) Counts() FaultCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts
+}
+
+// read a packet from the wrapped conn, after applying the In faults
+func (c *FaultConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		if len(c.pending) > 0 {
+			pkt := c.pending[0]
+			c.pending = c.pending[1:]
+			c.mu.Unlock()
+			return copy(p, pkt.data), pkt.addr, nil
+		}
+		c.mu.Unlock()
+
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return n, addr, err
+		}
+
+		c.mu.Lock()
+		data, delay, deliver := c.apply(&c.In, p[:n])
+		if !deliver {
+			c.mu.Unlock()
+			continue
+		}
+		if c.roll(c.In.Reorder) {
+			// deliver this one after the next packet to arrive
+			c.counts.Reordered++
+			held := packet{clone(data), addr}
+			c.mu.Unlock()
+
+			n, addr, err = c.ReadFrom(p)
+			c.mu.Lock()
+			c.pending = append(c.pending, held)
+			c.mu.Unlock()
+			return n, addr, err
+		}
+		if c.roll(c.In.Duplicate) {
+			c.counts.Duplicated++
+			c.pending = append(c.pending, packet{clone(data), addr})
+		}
+		c.mu.Unlock()
+
+		time.Sleep(delay)
+		return len(data), addr, nil
+	}
+}
+
+// write a packet to the wrapped conn, after applying the Out faults.
+// the full length of p is reported as written even if it was dropped
+// or truncated, as would be the case on a real network
+func (c *FaultConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	data, delay, deliver := c.apply(&c.Out, p)
+	if !deliver {
+		c.mu.Unlock()
+		return len(p), nil
+	}
+
+	out := []packet{{data, addr}}
+	if c.roll(c.Out.Duplicate) {
+		c.counts.Duplicated++
+		out = append(out, packet{data, addr})
+	}
+	if c.held != nil {
+		out = append(out, *c.held)
+		c.held = nil
+	} else if c.roll(c.Out.Reorder) {
+		// send this one after the next packet is written
+		c.counts.Reordered++
+		c.held = &packet{clone(data), addr}
+		c.mu.Unlock()
+		return len(p), nil
+	}
+	c.mu.Unlock()
+
+	time.Sleep(delay)
+	for _, pkt := range out {
+		_, err := c.PacketConn.WriteTo(pkt.data, pkt.addr)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// roll for the drop, truncate and delay faults in f, returning the
+// data to deliver, how long to wait first, and whether to deliver it.
+// c.mu must be held
+func (c *FaultConn) apply(f *Faults, data []byte) ([]byte, time.Duration, bool) {
+	if c.roll(f.Drop) {
+		c.counts.Dropped++
+		return nil, 0, false
+	}
+	if len(data) > 0 && c.roll(f.Truncate) {
+		c.counts.Truncated++
+		data = data[:c.rand.Intn(len(data))]
+	}
+	var delay time.Duration
+	if f.MaxDelay > 0 && c.roll(f.Delay) {
+		c.counts.Delayed++
+		delay = time.Duration(c.rand.Int63n(int64(f.MaxDelay)))
+	}
+	return data, delay, true
+}
+
+// decide whether an event with probability p happens.
+// c.mu must be held
+func (c *FaultConn) roll(p float64) bool {
+	return p > 0 && c.rand.Float64() < p
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
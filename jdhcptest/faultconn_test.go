@@ -0,0 +1,148 @@
+package jdhcptest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// open a pair of connected loopback sockets, with the
+// sending side wrapped in a FaultConn
+func faultPair(t *testing.T, seed int64) (*FaultConn, net.PacketConn) {
+	a, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	b, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return NewFaultConn(a, seed), b
+}
+
+// send each of pkts from c to r, then read back everything that arrives
+func exchange(t *testing.T, c *FaultConn, r net.PacketConn, pkts [][]byte) [][]byte {
+	for _, p := range pkts {
+		_, err := c.WriteTo(p, r.LocalAddr())
+		if err != nil {
+			t.Fatalf("write failed: %s", err)
+		}
+	}
+
+	var got [][]byte
+	buf := make([]byte, 1500)
+	for {
+		r.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, _, err := r.ReadFrom(buf)
+		if err != nil {
+			return got
+		}
+		got = append(got, clone(buf[:n]))
+	}
+}
+
+func numbered(n int) [][]byte {
+	pkts := make([][]byte, n)
+	for i := range pkts {
+		pkts[i] = bytes.Repeat([]byte{byte(i)}, 16)
+	}
+	return pkts
+}
+
+func TestFaultConnPassthrough(t *testing.T) {
+	c, r := faultPair(t, 1)
+	pkts := numbered(5)
+
+	got := exchange(t, c, r, pkts)
+	if len(got) != len(pkts) {
+		t.Fatalf("expected %d packets got %d", len(pkts), len(got))
+	}
+	for i := range pkts {
+		if bytes.Compare(pkts[i], got[i]) != 0 {
+			t.Errorf("packet %d expected %v got %v", i, pkts[i], got[i])
+		}
+	}
+}
+
+func TestFaultConnOut(t *testing.T) {
+	c, r := faultPair(t, 1)
+
+	c.Out = Faults{Drop: 1}
+	if got := exchange(t, c, r, numbered(5)); len(got) != 0 {
+		t.Errorf("expected all packets dropped, got %d", len(got))
+	}
+
+	c.Out = Faults{Duplicate: 1}
+	if got := exchange(t, c, r, numbered(5)); len(got) != 10 {
+		t.Errorf("expected all packets duplicated, got %d", len(got))
+	}
+
+	c.Out = Faults{Truncate: 1}
+	for i, p := range exchange(t, c, r, numbered(5)) {
+		if len(p) >= 16 {
+			t.Errorf("packet %d was not truncated", i)
+		}
+	}
+
+	c.Out = Faults{Reorder: 1}
+	got := exchange(t, c, r, numbered(2))
+	if len(got) != 2 || got[0][0] != 1 || got[1][0] != 0 {
+		t.Errorf("expected packets to be swapped, got %v", got)
+	}
+
+	counts := c.Counts()
+	if counts.Dropped != 5 || counts.Duplicated != 5 || counts.Truncated != 5 || counts.Reordered != 1 {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+}
+
+func TestFaultConnIn(t *testing.T) {
+	s, r := faultPair(t, 1)
+	c := NewFaultConn(r, 1)
+	c.In = Faults{Duplicate: 1}
+
+	for _, p := range numbered(2) {
+		_, err := s.WriteTo(p, r.LocalAddr())
+		if err != nil {
+			t.Fatalf("write failed: %s", err)
+		}
+	}
+
+	buf := make([]byte, 1500)
+	for _, want := range []byte{0, 0, 1, 1} {
+		c.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read failed: %s", err)
+		}
+		if n != 16 || buf[0] != want {
+			t.Errorf("expected packet %d got %v", want, buf[:n])
+		}
+	}
+}
+
+func TestFaultConnDeterministic(t *testing.T) {
+	f := Faults{Drop: 0.3, Truncate: 0.3}
+
+	c1, r1 := faultPair(t, 42)
+	c1.Out = f
+	got1 := exchange(t, c1, r1, numbered(20))
+
+	c2, r2 := faultPair(t, 42)
+	c2.Out = f
+	got2 := exchange(t, c2, r2, numbered(20))
+
+	if len(got1) != len(got2) {
+		t.Fatalf("same seed delivered %d and %d packets", len(got1), len(got2))
+	}
+	for i := range got1 {
+		if bytes.Compare(got1[i], got2[i]) != 0 {
+			t.Errorf("packet %d differs between runs: %v and %v", i, got1[i], got2[i])
+		}
+	}
+}
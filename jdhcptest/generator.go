@@ -0,0 +1,157 @@
+package jdhcptest
+
+import (
+	"encoding/binary"
+	"github.com/aktungmak/jdhcp"
+	"math/rand"
+	"net"
+)
+
+// a Generator produces random DHCP messages for use as fuzz corpus
+// and in randomised tests. it is created from a seed, and always
+// produces the same sequence of messages for the same seed.
+//
+// it is not safe for concurrent access by multiple goroutines
+type Generator struct {
+	rand *rand.Rand
+}
+
+// create a Generator using seed for its random source
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// generate a valid Msg, which can be marshalled and parsed again.
+// the options are random, but their values are not necessarily
+// valid for the option code
+func (g *Generator) Msg() *jdhcp.Msg {
+	m := jdhcp.NewMsg()
+	m.Op = byte(1 + g.rand.Intn(2))
+	m.Htype = 1
+	m.Hlen = 6
+	m.Hops = byte(g.rand.Intn(4))
+	m.Xid = g.rand.Uint32()
+	m.Secs = uint16(g.rand.Intn(1 << 16))
+	if g.rand.Intn(2) == 0 {
+		m.Flags = 0x8000 // broadcast
+	}
+	m.Ciaddr = g.ip()
+	m.Yiaddr = g.ip()
+	m.Siaddr = g.ip()
+	m.Giaddr = g.ip()
+	m.Chaddr = net.HardwareAddr(g.bytes(6))
+	m.Sname = g.text(63)
+	m.File = g.text(127)
+
+	m.Options[jdhcp.OptionDHCPMessageType] = []byte{byte(1 + g.rand.Intn(8))}
+
+	// keep within the size every client can accept, leaving room
+	// for the IP and UDP headers, header fields and message type
+	space := jdhcp.DefaultMaxMessageSize - 28 - 240 - 3 - 1
+	for i := g.rand.Intn(16); i > 0; i-- {
+		code := jdhcp.OptionCode(1 + g.rand.Intn(254))
+		if code == jdhcp.OptionOverload || code == jdhcp.OptionDHCPMessageType {
+			continue
+		}
+		v := g.bytes(g.rand.Intn(32))
+		if _, ok := m.Options[code]; ok || space < 2+len(v) {
+			continue
+		}
+		m.Options[code] = v
+		space -= 2 + len(v)
+	}
+
+	return m
+}
+
+// generate the bytes of a message which is close to being valid,
+// such as one with an odd hlen, a bad cookie, truncated options
+// or an unusual size. these are meant to exercise error handling
+func (g *Generator) Bytes() []byte {
+	b, err := g.Msg().MarshalBytes()
+	if err != nil {
+		// generated messages always have IPv4 addresses
+		panic(err)
+	}
+
+	// find the end option, so the options can be changed
+	end := len(b) - 1
+	for end > 240 && b[end] != byte(jdhcp.OptionEnd) {
+		end--
+	}
+
+	switch g.rand.Intn(8) {
+	case 0: // odd hlen
+		b[2] = []byte{0, 1, 7, 16, 17, 255}[g.rand.Intn(6)]
+	case 1: // bad cookie
+		binary.BigEndian.PutUint32(b[236:240], g.rand.Uint32())
+	case 2: // cut off at a field boundary or anywhere
+		cuts := []int{0, 1, 12, 28, 44, 108, 235, 236, 239, 240, 241}
+		cuts = append(cuts, g.rand.Intn(len(b)))
+		b = b[:cuts[g.rand.Intn(len(cuts))]]
+	case 3: // option length running past the end
+		b = append(b[:end], byte(1+g.rand.Intn(254)), 0xff, 0x01)
+	case 4: // no end option and no padding
+		b = b[:end]
+	case 5: // padded out to a boundary size
+		sizes := []int{272, 548, 576, 1472, 1500}
+		size := sizes[g.rand.Intn(len(sizes))]
+		for len(b) < size {
+			b = append(b, 0)
+		}
+	case 6: // overload option pointing at random file and sname
+		b = append(b[:end], byte(jdhcp.OptionOverload), 1, byte(g.rand.Intn(4)), 0xff)
+		g.rand.Read(b[44:236])
+	default: // random bit flips
+		for i := 1 + g.rand.Intn(4); i > 0; i-- {
+			b[g.rand.Intn(len(b))] ^= 1 << uint(g.rand.Intn(8))
+		}
+	}
+
+	return b
+}
+
+// generate n messages, alternating between marshalled valid ones
+// and near-valid ones from Bytes, suitable for seeding a fuzzer
+func (g *Generator) Corpus(n int) [][]byte {
+	corpus := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			b, err := g.Msg().MarshalBytes()
+			if err != nil {
+				panic(err)
+			}
+			corpus = append(corpus, b)
+		} else {
+			corpus = append(corpus, g.Bytes())
+		}
+	}
+	return corpus
+}
+
+// a random IPv4 address, which is often 0.0.0.0
+func (g *Generator) ip() net.IP {
+	if g.rand.Intn(2) == 0 {
+		return net.IPv4(0, 0, 0, 0)
+	}
+	b := g.bytes(4)
+	return net.IPv4(b[0], b[1], b[2], b[3])
+}
+
+func (g *Generator) bytes(n int) []byte {
+	b := make([]byte, n)
+	g.rand.Read(b)
+	return b
+}
+
+// random printable text of up to n characters, which is often empty
+func (g *Generator) text(n int) string {
+	if g.rand.Intn(2) == 0 {
+		return ""
+	}
+	b := make([]byte, 1+g.rand.Intn(n))
+	for i := range b {
+		b[i] = byte(' ' + g.rand.Intn('~'-' '+1))
+	}
+	return string(b)
+}
@@ -0,0 +1,62 @@
+package jdhcptest
+
+import (
+	"bytes"
+	"github.com/aktungmak/jdhcp"
+	"testing"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	c1 := NewGenerator(7).Corpus(50)
+	c2 := NewGenerator(7).Corpus(50)
+
+	for i := range c1 {
+		if bytes.Compare(c1[i], c2[i]) != 0 {
+			t.Fatalf("message %d differs for the same seed", i)
+		}
+	}
+}
+
+func TestGeneratorMsg(t *testing.T) {
+	g := NewGenerator(1)
+	for i := 0; i < 200; i++ {
+		m := g.Msg()
+		b, err := m.MarshalBytes()
+		if err != nil {
+			t.Fatalf("message %d could not be marshalled: %s", i, err)
+		}
+		if len(b) > jdhcp.DefaultMaxMessageSize-28 {
+			t.Errorf("message %d is %d bytes", i, len(b))
+		}
+
+		err = CheckMsgRoundTrip(m)
+		if err != nil {
+			t.Errorf("message %d: %s", i, err)
+		}
+	}
+}
+
+func TestGeneratorBytes(t *testing.T) {
+	g := NewGenerator(1)
+	for i := 0; i < 1000; i++ {
+		b := g.Bytes()
+
+		// these must not panic, whether or not they succeed
+		jdhcp.ParseMsg(b)
+		jdhcp.ParseMsgPartial(b)
+	}
+}
+
+func FuzzParseMsg(f *testing.F) {
+	for _, b := range NewGenerator(1).Corpus(64) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		jdhcp.ParseMsgPartial(b)
+
+		err := CheckBytesStable(b)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package jdhcptest
+
+import (
+	"bytes"
+	"github.com/aktungmak/jdhcp"
+	"github.com/pkg/errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// check that m is unchanged by marshalling it and parsing the
+// result, returning an error describing any differences. addresses
+// are compared by value, so 4 and 16 byte forms are equivalent and
+// a nil address is the same as 0.0.0.0
+func CheckMsgRoundTrip(m *jdhcp.Msg) error {
+	b, err := m.MarshalBytes()
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+	got, err := jdhcp.ParseMsg(b)
+	if err != nil {
+		return errors.Wrap(err, "parse marshalled message")
+	}
+
+	diffs := MsgDiff(m, got)
+	if len(diffs) > 0 {
+		return errors.Errorf("round trip changed %s", strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// check that the bytes produced by parsing b and marshalling the
+// result are stable, meaning that parsing and marshalling them again
+// gives exactly the same bytes. the marshalled form is not expected to
+// match b itself, as padding and option order are normalised. inputs
+// which ParseMsg rejects are not checked
+func CheckBytesStable(b []byte) error {
+	m, err := jdhcp.ParseMsg(b)
+	if err != nil {
+		return nil
+	}
+	b1, err := m.MarshalBytes()
+	if err != nil {
+		return errors.Wrap(err, "marshal parsed message")
+	}
+
+	m, err = jdhcp.ParseMsg(b1)
+	if err != nil {
+		return errors.Wrap(err, "parse marshalled message")
+	}
+	b2, err := m.MarshalBytes()
+	if err != nil {
+		return errors.Wrap(err, "marshal parsed message again")
+	}
+
+	if !bytes.Equal(b1, b2) {
+		for i := range b1 {
+			if i >= len(b2) || b1[i] != b2[i] {
+				return errors.Errorf("marshalled bytes changed at offset %d", i)
+			}
+		}
+		return errors.Errorf("marshalled length changed from %d to %d", len(b1), len(b2))
+	}
+	return nil
+}
+
+// compare two messages in the same way as CheckMsgRoundTrip,
+// returning the names of the fields and options which differ
+func MsgDiff(a, b *jdhcp.Msg) []string {
+	var diffs []string
+	for _, f := range []struct {
+		name string
+		same bool
+	}{
+		{"op", a.Op == b.Op},
+		{"htype", a.Htype == b.Htype},
+		{"hlen", a.Hlen == b.Hlen},
+		{"hops", a.Hops == b.Hops},
+		{"xid", a.Xid == b.Xid},
+		{"secs", a.Secs == b.Secs},
+		{"flags", a.Flags == b.Flags},
+		{"ciaddr", sameIP(a.Ciaddr, b.Ciaddr)},
+		{"yiaddr", sameIP(a.Yiaddr, b.Yiaddr)},
+		{"siaddr", sameIP(a.Siaddr, b.Siaddr)},
+		{"giaddr", sameIP(a.Giaddr, b.Giaddr)},
+		{"chaddr", bytes.Equal(a.Chaddr, b.Chaddr)},
+		{"sname", a.Sname == b.Sname},
+		{"file", a.File == b.File},
+	} {
+		if !f.same {
+			diffs = append(diffs, f.name)
+		}
+	}
+
+	var codes []jdhcp.OptionCode
+	for k, av := range a.Options {
+		bv, ok := b.Options[k]
+		if !ok || !bytes.Equal(av, bv) {
+			codes = append(codes, k)
+		}
+	}
+	for k := range b.Options {
+		if _, ok := a.Options[k]; !ok {
+			codes = append(codes, k)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, k := range codes {
+		diffs = append(diffs, "option "+strconv.Itoa(int(k)))
+	}
+
+	return diffs
+}
+
+func sameIP(a, b net.IP) bool {
+	if a == nil {
+		a = net.IPv4zero
+	}
+	if b == nil {
+		b = net.IPv4zero
+	}
+	return a.Equal(b)
+}
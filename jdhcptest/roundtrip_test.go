@@ -0,0 +1,55 @@
+package jdhcptest
+
+import (
+	"github.com/aktungmak/jdhcp"
+	"net"
+	"testing"
+)
+
+func TestCheckMsgRoundTrip(t *testing.T) {
+	m := jdhcp.NewMsg()
+	m.Hlen = 6
+	m.Yiaddr = net.IPv4(192, 168, 1, 10).To4()
+	m.Ciaddr = nil
+	m.Options[jdhcp.OptionDHCPMessageType] = []byte{byte(jdhcp.Offer)}
+
+	err := CheckMsgRoundTrip(m)
+	if err != nil {
+		t.Fatalf("valid message failed: %s", err)
+	}
+
+	// trailing NULs are lost from sname
+	m.Sname = "server\000"
+	err = CheckMsgRoundTrip(m)
+	if err == nil {
+		t.Fatalf("expected sname to change")
+	}
+}
+
+func TestCheckBytesStable(t *testing.T) {
+	for i, b := range NewGenerator(3).Corpus(500) {
+		err := CheckBytesStable(b)
+		if err != nil {
+			t.Errorf("message %d: %s", i, err)
+		}
+	}
+}
+
+func TestMsgDiff(t *testing.T) {
+	a := jdhcp.NewMsg()
+	b := jdhcp.NewMsg()
+	a.Options[jdhcp.OptionSubnetMask] = []byte{255, 255, 255, 0}
+	b.Options[jdhcp.OptionRequestedIPAddress] = []byte{10, 0, 0, 1}
+	b.Xid = 1
+
+	got := MsgDiff(a, b)
+	expected := []string{"xid", "option 1", "option 50"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package jdhcp
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// a Lease is what a server knows about a lease, as reported in the
+// reply to a DHCPLEASEQUERY (RFC4388). only Status is always set, the
+// other fields are left empty or zero unless the server reports them
+type Lease struct {
+	Status          MessageType // LeaseActive, LeaseUnassigned or LeaseUnknown
+	IP              net.IP
+	HardwareAddr    net.HardwareAddr
+	ClientID        []byte
+	Remaining       time.Duration // until the lease expires, or InfiniteLease
+	LastTransaction time.Duration // since the client last talked to the server
+	AssociatedIPs   []net.IP      // other leases of the same client
+}
+
+// create a DHCPLEASEQUERY for the lease of the address ip
+func NewLeaseQueryByIP(ip net.IP) (*Msg, error) {
+	m := newLeaseQuery()
+	return m, m.SetCiaddr(ip)
+}
+
+// create a DHCPLEASEQUERY for the leases of the client with hardware
+// address hw, which is sent with the ethernet hardware type
+func NewLeaseQueryByMAC(hw net.HardwareAddr) (*Msg, error) {
+	m := newLeaseQuery()
+	m.Htype = 1
+	return m, m.SetChaddr(hw)
+}
+
+// create a DHCPLEASEQUERY for the leases of the client with the
+// client identifier id, the whole value of option 61
+func NewLeaseQueryByClientID(id []byte) *Msg {
+	m := newLeaseQuery()
+	m.Options[OptionClientID] = id
+	return m
+}
+
+func newLeaseQuery() *Msg {
+	m := NewMsg()
+	m.Op = 1
+	m.Xid = rand.Uint32()
+	m.Options[OptionDHCPMessageType] = []byte{byte(LeaseQuery)}
+	m.Options[OptionParameterRequestList] = []byte{
+		byte(OptionIPAddressLeaseTime),
+		byte(OptionClientLastTransactionTime),
+		byte(OptionAssociatedIP),
+	}
+	return m
+}
+
+// send the DHCPLEASEQUERY query to the server at addr over conn and
+// wait for the reply, until ctx is done. the giaddr of query should be
+// set to the address of conn, as the server replies there. replies to
+// other queries received on conn are ignored
+func QueryLease(ctx context.Context, conn net.PacketConn, addr net.Addr, query *Msg) (*Lease, error) {
+	payload, err := query.MarshalBytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal leasequery")
+	}
+	if _, err := conn.WriteTo(payload, addr); err != nil {
+		return nil, errors.Wrap(err, "send leasequery")
+	}
+
+	// stop waiting once ctx is done
+	defer watchContext(ctx, conn)()
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.Wrap(ctx.Err(), "wait for leasequery reply")
+			}
+			return nil, errors.Wrap(err, "read leasequery reply")
+		}
+		res, err := parseLeaseReply(buf[:n])
+		if err != nil || res.Op != 2 || res.Xid != query.Xid {
+			continue
+		}
+		return ParseLease(res)
+	}
+}
+
+// parse a reply to a DHCPLEASEQUERY. unlike ParseMsg, a reply without
+// a hardware address (hlen 0) is accepted, as servers send these when
+// they know nothing about the client
+func parseLeaseReply(data []byte) (*Msg, error) {
+	res, err := ParseMsg(data)
+	var pe *ParseError
+	if errors.As(err, &pe) && pe.Field == "hlen" && data[2] == 0 {
+		res, problems := ParseMsgPartial(data)
+		if len(problems) == 1 {
+			return res, nil
+		}
+	}
+	return res, err
+}
+
+// get the Lease described by the reply res to a DHCPLEASEQUERY
+func ParseLease(res *Msg) (*Lease, error) {
+	mt, err := res.Options.DHCPMessageType()
+	if err != nil {
+		return nil, err
+	}
+	switch mt {
+	case LeaseActive, LeaseUnassigned, LeaseUnknown:
+	default:
+		return nil, errors.Errorf("message type %d is not a leasequery reply", mt)
+	}
+
+	l := &Lease{Status: mt}
+	if res.Ciaddr != nil && !res.Ciaddr.IsUnspecified() {
+		l.IP = res.Ciaddr
+	}
+	if len(res.Chaddr) > 0 {
+		l.HardwareAddr = res.Chaddr
+	}
+	if id, ok := res.Options[OptionClientID]; ok {
+		l.ClientID = id
+	}
+	if _, ok := res.Options[OptionIPAddressLeaseTime]; ok {
+		if l.Remaining, err = res.Options.IPAddressLeaseTime(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := res.Options[OptionClientLastTransactionTime]; ok {
+		if l.LastTransaction, err = res.Options.ClientLastTransactionTime(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := res.Options[OptionAssociatedIP]; ok {
+		if l.AssociatedIPs, err = res.Options.AssociatedIPs(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// interrupt the reads of conn once ctx is done, by setting a read
+// deadline in the past. the returned function stops this and then
+// clears the deadline, so conn can be read from again afterwards
+func watchContext(ctx context.Context, conn interface{ SetReadDeadline(time.Time) error }) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() {
+		// the deadline is only cleared once the watcher cannot set
+		// it any more, or it could be left in the past
+		close(done)
+		<-exited
+		conn.SetReadDeadline(time.Time{})
+	}
+}
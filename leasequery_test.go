@@ -0,0 +1,216 @@
+package jdhcp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQueryLease(t *testing.T) {
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer server.Close()
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer client.Close()
+
+	// answer one query for 10.0.0.7 as an active lease, after
+	// sending a reply with the wrong xid which should be ignored.
+	// queries by address have no hardware address, so ParseMsg
+	// would reject them
+	go func() {
+		buf := make([]byte, 1500)
+		n, from, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, problems := ParseMsgPartial(buf[:n])
+		if len(problems) > 1 {
+			return
+		}
+		res := NewMsg()
+		res.Op = 2
+		res.Xid = req.Xid + 1
+		res.Options[OptionDHCPMessageType] = []byte{byte(LeaseActive)}
+		b, _ := res.MarshalBytes()
+		server.WriteTo(b, from)
+
+		res.Xid = req.Xid
+		res.Ciaddr = req.Ciaddr
+		res.SetChaddr(net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+		res.Options[OptionDHCPMessageType] = []byte{byte(LeaseActive)}
+		res.Options[OptionIPAddressLeaseTime] = []byte{0, 0, 0x0e, 0x10}
+		res.Options[OptionClientLastTransactionTime] = []byte{0, 0, 0, 30}
+		res.Options[OptionAssociatedIP] = []byte{10, 0, 0, 7, 10, 0, 0, 8}
+		b, _ = res.MarshalBytes()
+		server.WriteTo(b, from)
+	}()
+
+	q, err := NewLeaseQueryByIP(net.IPv4(10, 0, 0, 7))
+	if err != nil {
+		t.Fatalf("cannot create query: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l, err := QueryLease(ctx, client, server.LocalAddr(), q)
+	if err != nil {
+		t.Fatalf("leasequery failed: %s", err)
+	}
+
+	if l.Status != LeaseActive {
+		t.Errorf("expected status %d got %d", LeaseActive, l.Status)
+	}
+	if !l.IP.Equal(net.IPv4(10, 0, 0, 7)) {
+		t.Errorf("expected ip 10.0.0.7 got %s", l.IP)
+	}
+	if l.HardwareAddr.String() != "00:11:22:33:44:55" {
+		t.Errorf("expected mac 00:11:22:33:44:55 got %s", l.HardwareAddr)
+	}
+	if l.Remaining != time.Hour || l.LastTransaction != 30*time.Second {
+		t.Errorf("expected times 1h and 30s got %s and %s", l.Remaining, l.LastTransaction)
+	}
+	if len(l.AssociatedIPs) != 2 {
+		t.Errorf("expected 2 associated ips got %v", l.AssociatedIPs)
+	}
+}
+
+func TestQueryLeaseTimeout(t *testing.T) {
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = QueryLease(ctx, client, client.LocalAddr(), NewLeaseQueryByClientID([]byte{1, 2, 3}))
+	if err == nil {
+		t.Fatalf("expected query to time out")
+	}
+}
+
+// a PacketConn which cancels a context as its read deadline is
+// cleared, giving anything watching the context time to act after that
+type cancelOnClear struct {
+	net.PacketConn
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClear) SetReadDeadline(t time.Time) error {
+	err := c.PacketConn.SetReadDeadline(t)
+	if t.IsZero() {
+		c.cancel()
+		time.Sleep(20 * time.Millisecond)
+	}
+	return err
+}
+
+func TestQueryLeaseCancelled(t *testing.T) {
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer server.Close()
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer client.Close()
+
+	go func() {
+		buf := make([]byte, 1500)
+		n, from, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, _ := ParseMsgPartial(buf[:n])
+		res := NewMsg()
+		res.Op = 2
+		res.Xid = req.Xid
+		res.Options[OptionDHCPMessageType] = []byte{byte(LeaseUnknown)}
+		b, _ := res.MarshalBytes()
+		server.WriteTo(b, from)
+	}()
+
+	// the context is cancelled just as the query finishes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := &cancelOnClear{client, cancel}
+	if _, err := QueryLease(ctx, conn, server.LocalAddr(), NewLeaseQueryByClientID([]byte{1, 2, 3})); err != nil {
+		t.Fatalf("leasequery failed: %s", err)
+	}
+
+	// which must leave the conn usable
+	if _, err := server.WriteTo([]byte("ping"), client.LocalAddr()); err != nil {
+		t.Fatalf("cannot send: %s", err)
+	}
+	buf := make([]byte, 16)
+	if _, _, err := client.ReadFrom(buf); err != nil {
+		t.Fatalf("conn unusable after the query: %s", err)
+	}
+}
+
+func TestParseLease(t *testing.T) {
+	res := NewMsg()
+	res.Options[OptionDHCPMessageType] = []byte{byte(ACK)}
+	if _, err := ParseLease(res); err == nil {
+		t.Errorf("expected error for a DHCPACK")
+	}
+
+	res.Options[OptionDHCPMessageType] = []byte{byte(LeaseUnassigned)}
+	res.Options[OptionClientID] = []byte{1, 2, 3}
+	l, err := ParseLease(res)
+	if err != nil {
+		t.Fatalf("cannot parse lease: %s", err)
+	}
+	if l.Status != LeaseUnassigned || l.IP != nil || !bytes.Equal(l.ClientID, []byte{1, 2, 3}) {
+		t.Errorf("unexpected lease %+v", l)
+	}
+
+	res.Options[OptionIPAddressLeaseTime] = []byte{1, 2}
+	if _, err := ParseLease(res); err == nil {
+		t.Errorf("expected error for a short lease time")
+	}
+}
+
+func TestNewLeaseQueryByMAC(t *testing.T) {
+	q, err := NewLeaseQueryByMAC(net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err != nil {
+		t.Fatalf("cannot create query: %s", err)
+	}
+	if q.Htype != 1 || q.Hlen != 6 {
+		t.Errorf("expected htype 1 and hlen 6 got %d and %d", q.Htype, q.Hlen)
+	}
+	if mt, _ := q.Options.DHCPMessageType(); mt != LeaseQuery {
+		t.Errorf("expected message type %d got %d", LeaseQuery, mt)
+	}
+}
+
+func TestParseLeaseReplyNoHardwareAddr(t *testing.T) {
+	res := NewMsg()
+	res.Op = 2
+	res.Options[OptionDHCPMessageType] = []byte{byte(LeaseUnknown)}
+	b, err := res.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal reply: %s", err)
+	}
+
+	got, err := parseLeaseReply(b)
+	if err != nil {
+		t.Fatalf("cannot parse reply with hlen 0: %s", err)
+	}
+	if len(got.Chaddr) != 0 {
+		t.Errorf("expected no chaddr got %s", got.Chaddr)
+	}
+
+	b[2] = 17
+	if _, err := parseLeaseReply(b); err == nil {
+		t.Errorf("expected error for hlen 17")
+	}
+}
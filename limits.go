@@ -0,0 +1,55 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ParseLimits caps the resources used to parse a single message, so
+// that a flood of hostile packets cannot make the parser allocate
+// without bound. a zero field means that quantity is not limited
+type ParseLimits struct {
+	MaxSize      int // total size of the message in bytes
+	MaxOptions   int // number of options, including repeated ones
+	MaxOptionLen int // length of the value of any one option
+}
+
+// the limits used by a Server unless it is configured otherwise.
+// they allow any message that fits in an ethernet frame
+var DefaultParseLimits = ParseLimits{
+	MaxSize:    1500 - ipUDPHeaderLen,
+	MaxOptions: 128,
+}
+
+// parse a slice of bytes as a DHCP message, failing with an error
+// wrapping ErrLimitExceeded if it is larger than limits allow
+func ParseMsgLimited(data []byte, limits ParseLimits) (*Msg, error) {
+	msg := &Msg{Options: Options{}}
+	err := parseMsgInto(msg, data, limits)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// check the size of a whole message against the limits
+func (pl ParseLimits) checkSize(n int) error {
+	if pl.MaxSize > 0 && n > pl.MaxSize {
+		return errors.Wrapf(ErrLimitExceeded,
+			"message of %d bytes is larger than %d", n, pl.MaxSize)
+	}
+	return nil
+}
+
+// check the option with the given code and length, which is the
+// count'th one found, against the limits
+func (pl ParseLimits) checkOption(count int, code OptionCode, l int) error {
+	if pl.MaxOptions > 0 && count > pl.MaxOptions {
+		return errors.Wrapf(ErrLimitExceeded,
+			"more than %d options", pl.MaxOptions)
+	}
+	if pl.MaxOptionLen > 0 && l > pl.MaxOptionLen {
+		return errors.Wrapf(ErrLimitExceeded,
+			"option %d of %d bytes is longer than %d", code, l, pl.MaxOptionLen)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestParseMsgLimited(t *testing.T) {
+	data := messageParseCases[0].asBytes
+	opts := messageParseCases[0].asStruct.Options
+
+	for i, tc := range []struct {
+		limits ParseLimits
+		ok     bool
+	}{
+		{ParseLimits{}, true},
+		{DefaultParseLimits, true},
+		{ParseLimits{MaxSize: len(data)}, true},
+		{ParseLimits{MaxSize: len(data) - 1}, false},
+		{ParseLimits{MaxOptions: len(opts)}, true},
+		{ParseLimits{MaxOptions: len(opts) - 1}, false},
+		{ParseLimits{MaxOptionLen: 7}, true},
+		{ParseLimits{MaxOptionLen: 6}, false},
+	} {
+		_, err := ParseMsgLimited(data, tc.limits)
+		if tc.ok && err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+		if !tc.ok && errors.Cause(err) != ErrLimitExceeded {
+			t.Errorf("case %d: expected ErrLimitExceeded, got %v", i, err)
+		}
+	}
+}
+
+func TestParseMsgLimitedRepeatedOptions(t *testing.T) {
+	data := append([]byte(nil), messageParseCases[0].asBytes[:240]...)
+	for i := 0; i < 200; i++ {
+		data = append(data, byte(OptionSubnetMask), 4, 255, 255, 255, 0)
+	}
+	data = append(data, byte(OptionEnd))
+
+	_, err := ParseMsgLimited(data, ParseLimits{MaxOptions: 128})
+	if errors.Cause(err) != ErrLimitExceeded {
+		t.Errorf("expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestParseMsgLimitedPadding(t *testing.T) {
+	// Pad is not an option, so heavy padding stays within the limits
+	data := append([]byte(nil), messageParseCases[0].asBytes[:240]...)
+	for i := 0; i < 200; i++ {
+		data = append(data, byte(OptionPad))
+	}
+	data = append(data, byte(OptionDHCPMessageType), 1, byte(Discover), byte(OptionEnd))
+
+	msg, err := ParseMsgLimited(data, DefaultParseLimits)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if t2, _ := msg.DHCPMessageType(); t2 != Discover {
+		t.Errorf("expected DHCPDISCOVER got %d", t2)
+	}
+	if _, err := ParseMsgLimited(data, ParseLimits{MaxOptions: 1}); err != nil {
+		t.Errorf("unexpected error with one option allowed: %s", err)
+	}
+}
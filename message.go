@@ -3,7 +3,6 @@ package jdhcp
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"github.com/pkg/errors"
 	"net"
 )
@@ -29,62 +28,313 @@ type Msg struct {
 
 // initialise a blank Msg, should be used to ensure correct init
 func NewMsg() *Msg {
-	return &Msg{
-		Ciaddr:  net.IPv4(0, 0, 0, 0),
-		Yiaddr:  net.IPv4(0, 0, 0, 0),
-		Siaddr:  net.IPv4(0, 0, 0, 0),
-		Giaddr:  net.IPv4(0, 0, 0, 0),
-		Chaddr:  net.HardwareAddr([]byte{0, 0, 0, 0, 0, 0}),
-		Options: Options{},
+	m := &Msg{Options: Options{}}
+	m.init()
+	return m
+}
+
+// set the address fields of a Msg to their blank values
+func (m *Msg) init() {
+	m.Ciaddr = net.IPv4(0, 0, 0, 0)
+	m.Yiaddr = net.IPv4(0, 0, 0, 0)
+	m.Siaddr = net.IPv4(0, 0, 0, 0)
+	m.Giaddr = net.IPv4(0, 0, 0, 0)
+	m.Chaddr = net.HardwareAddr([]byte{0, 0, 0, 0, 0, 0})
+}
+
+// set the client IP address, which must be an IPv4 address
+func (m *Msg) SetCiaddr(ip net.IP) error {
+	return setIPv4(&m.Ciaddr, ip)
+}
+
+// set the "your" (client) IP address, which must be an IPv4 address
+func (m *Msg) SetYiaddr(ip net.IP) error {
+	return setIPv4(&m.Yiaddr, ip)
+}
+
+// set the next server IP address, which must be an IPv4 address
+func (m *Msg) SetSiaddr(ip net.IP) error {
+	return setIPv4(&m.Siaddr, ip)
+}
+
+// set the relay agent IP address, which must be an IPv4 address
+func (m *Msg) SetGiaddr(ip net.IP) error {
+	return setIPv4(&m.Giaddr, ip)
+}
+
+// set the client hardware address, which can be at most 16 bytes,
+// the same lengths ParseMsg accepts. Hlen is updated to match the
+// length of the address
+func (m *Msg) SetChaddr(hw net.HardwareAddr) error {
+	if len(hw) == 0 || len(hw) > 16 {
+		return errors.Errorf("unsupported chaddr length of %d", len(hw))
 	}
+	m.Chaddr = append(net.HardwareAddr(nil), hw...)
+	m.Hlen = byte(len(hw))
+	return nil
 }
 
-// parse a slice of bytes as a DHCP message
+// store a copy of the 4-byte form of ip in field
+func setIPv4(field *net.IP, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return errors.Wrapf(ErrNotIPv4, "%s", ip)
+	}
+	*field = append(net.IP(nil), ip4...)
+	return nil
+}
+
+// parse a slice of bytes as a DHCP message. if the option overload
+// option is set, the options in the file and sname fields are read
+// as well and the fields are left empty
 func ParseMsg(data []byte) (*Msg, error) {
-	if len(data) < 236 {
-		return nil, ErrShortRead
+	msg := &Msg{Options: Options{}}
+	err := parseMsgInto(msg, data, ParseLimits{})
+	if err != nil {
+		return nil, err
 	}
+	return msg, nil
+}
 
-	msg := &Msg{
-		Op:     data[0],
-		Htype:  data[1],
-		Hlen:   data[2],
-		Hops:   data[3],
-		Xid:    binary.BigEndian.Uint32(data[4:8]),
-		Secs:   binary.BigEndian.Uint16(data[8:10]),
-		Flags:  binary.BigEndian.Uint16(data[10:12]),
-		Ciaddr: net.IP(data[12:16]),
-		Yiaddr: net.IP(data[16:20]),
-		Siaddr: net.IP(data[20:24]),
-		Giaddr: net.IP(data[24:28]),
-		Chaddr: net.HardwareAddr(data[28:34]), // 6-byte MAC only
-		Sname:  string(bytes.TrimRight(data[44:108], "\000")),
-		File:   string(bytes.TrimRight(data[108:236], "\000")),
-	}
-
-	if msg.Hlen != 6 {
-		return nil, errors.Errorf("unsupported hlen of %d", msg.Hlen)
+// parse data into msg, which must have a non-nil and empty Options,
+// checking it against limits. the address fields of msg will refer
+// to the contents of data
+func parseMsgInto(msg *Msg, data []byte, limits ParseLimits) error {
+	if len(data) < 240 {
+		return &ParseError{Offset: len(data), Field: "header", Err: ErrShortRead}
+	}
+	err := limits.checkSize(len(data))
+	if err != nil {
+		return &ParseError{Offset: 0, Field: "message", Err: err}
+	}
+
+	*msg = Msg{
+		Op:      data[0],
+		Htype:   data[1],
+		Hlen:    data[2],
+		Hops:    data[3],
+		Xid:     binary.BigEndian.Uint32(data[4:8]),
+		Secs:    binary.BigEndian.Uint16(data[8:10]),
+		Flags:   binary.BigEndian.Uint16(data[10:12]),
+		Ciaddr:  net.IP(data[12:16]),
+		Yiaddr:  net.IP(data[16:20]),
+		Siaddr:  net.IP(data[20:24]),
+		Giaddr:  net.IP(data[24:28]),
+		Sname:   string(bytes.TrimRight(data[44:108], "\000")),
+		File:    string(bytes.TrimRight(data[108:236], "\000")),
+		Options: msg.Options,
+	}
+
+	if msg.Hlen == 0 || msg.Hlen > 16 {
+		return &ParseError{Offset: 2, Field: "hlen",
+			Err: errors.Errorf("unsupported hlen of %d", msg.Hlen)}
 	}
+	msg.Chaddr = net.HardwareAddr(data[28 : 28+int(msg.Hlen)])
 
 	cookie := binary.BigEndian.Uint32(data[236:240])
 	if Cookie != cookie {
-		fmt.Printf("data: %v\n", data)
-		return nil, errors.Errorf("incorrect cookie, expected %d got %d", Cookie, cookie)
+		return &ParseError{Offset: 236, Field: "cookie",
+			Err: errors.Errorf("incorrect cookie, expected %d got %d", Cookie, cookie)}
 	}
 
-	var err error
-	msg.Options, err = ParseOptions(data[240:])
+	err = parseOptionsInto(msg.Options, data[240:], limits)
+	if pe, ok := err.(*ParseError); ok {
+		pe.Offset += 240
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "parse options")
+		return err
 	}
 
-	return msg, nil
+	overload, err := msg.Options.takeOverload()
+	if err != nil {
+		return &ParseError{Offset: 240, Field: "options", Err: err}
+	}
+	for _, f := range msg.overloadFields(overload) {
+		extra := make(Options)
+		err = parseOptionsInto(extra, data[f.start:f.end], limits)
+		if pe, ok := err.(*ParseError); ok {
+			pe.Offset += f.start
+			pe.Field = f.name
+		}
+		if err != nil {
+			return err
+		}
+		msg.Options.merge(extra)
+		*f.value = ""
+	}
+
+	return nil
 }
 
-// convert a Msg structure to the network representation
-// TODO optimise the method of padding
-func (m *Msg) MarshalBytes() []byte {
+// the fields of a message which can hold options when they are
+// overloaded, and where they are
+type overloadField struct {
+	name       string
+	start, end int
+	value      *string
+}
+
+// get the fields named by the value of the overload option, in the
+// order their options are read (RFC2131 chapter 4.1)
+func (m *Msg) overloadFields(overload byte) []overloadField {
+	var fields []overloadField
+	if overload&1 != 0 {
+		fields = append(fields, overloadField{"file", 108, 236, &m.File})
+	}
+	if overload&2 != 0 {
+		fields = append(fields, overloadField{"sname", 44, 108, &m.Sname})
+	}
+	return fields
+}
+
+// remove the overload option, returning its value or 0 if it is
+// absent. it is removed so that a Msg which is parsed and then
+// marshaled again does not claim that its fields hold options
+func (o Options) takeOverload() (byte, error) {
+	v, ok := o[OptionOverload]
+	if !ok {
+		return 0, nil
+	}
+	delete(o, OptionOverload)
+	if len(v) != 1 || v[0] < 1 || v[0] > 3 {
+		return 0, &OptionError{OptionOverload, errors.Errorf("invalid value % x", v)}
+	}
+	return v[0], nil
+}
+
+// add the options in extra, read from an overloaded field. an option
+// which is in both is concatenated, as it was split (RFC3396)
+func (o Options) merge(extra Options) {
+	for k, v := range extra {
+		if prev, ok := o[k]; ok {
+			v = append(append([]byte(nil), prev...), v...)
+		}
+		o[k] = v
+	}
+}
+
+// parse a slice of bytes as a DHCP message, decoding as much as
+// possible instead of stopping at the first problem. this is
+// intended for tools which need to display malformed messages.
+// a Msg is always returned, along with a list of the problems
+// found in data, which is empty if the message is well formed
+func ParseMsgPartial(data []byte) (*Msg, []error) {
+	var problems []error
+
+	// decode a truncated header as if the rest was zeroes
+	hdr := data
+	if len(hdr) < 240 {
+		problems = append(problems, errors.Wrapf(ErrShortRead,
+			"header truncated to %d bytes", len(data)))
+		hdr = make([]byte, 240)
+		copy(hdr, data)
+	}
+
+	hlen := int(hdr[2])
+	if hlen == 0 || hlen > 16 {
+		problems = append(problems, errors.Errorf("unsupported hlen of %d", hlen))
+	}
+	if hlen > 16 {
+		hlen = 16
+	}
+
+	msg := &Msg{
+		Op:     hdr[0],
+		Htype:  hdr[1],
+		Hlen:   hdr[2],
+		Hops:   hdr[3],
+		Xid:    binary.BigEndian.Uint32(hdr[4:8]),
+		Secs:   binary.BigEndian.Uint16(hdr[8:10]),
+		Flags:  binary.BigEndian.Uint16(hdr[10:12]),
+		Ciaddr: net.IP(hdr[12:16]),
+		Yiaddr: net.IP(hdr[16:20]),
+		Siaddr: net.IP(hdr[20:24]),
+		Giaddr: net.IP(hdr[24:28]),
+		Chaddr: net.HardwareAddr(hdr[28 : 28+hlen]),
+		Sname:  string(bytes.TrimRight(hdr[44:108], "\000")),
+		File:   string(bytes.TrimRight(hdr[108:236], "\000")),
+	}
+
+	cookie := binary.BigEndian.Uint32(hdr[236:240])
+	if Cookie != cookie {
+		problems = append(problems, errors.Errorf(
+			"incorrect cookie, expected %d got %d", Cookie, cookie))
+	}
+
+	var opts []error
+	if len(data) > 240 {
+		msg.Options, opts = ParseOptionsPartial(data[240:])
+	} else {
+		msg.Options = Options{}
+	}
+	for _, err := range opts {
+		problems = append(problems, errors.Wrap(err, "parse options"))
+	}
+
+	overload, err := msg.Options.takeOverload()
+	if err != nil {
+		problems = append(problems, errors.Wrap(err, "parse options"))
+	}
+	for _, f := range msg.overloadFields(overload) {
+		extra, opts := ParseOptionsPartial(hdr[f.start:f.end])
+		for _, err := range opts {
+			problems = append(problems, errors.Wrapf(err, "parse options in %s", f.name))
+		}
+		msg.Options.merge(extra)
+		*f.value = ""
+	}
+
+	return msg, problems
+}
+
+// convert a Msg structure to the network representation.
+// an error is returned if any of the address fields is not an
+// IPv4 address, a nil address is treated as 0.0.0.0
+func (m *Msg) MarshalBytes() ([]byte, error) {
+	var b bytes.Buffer
+	return m.marshalTo(&b, nil, 0)
+}
+
+// convert a Msg structure to the network representation, writing
+// the options in the order described by Options.MarshalBytesOrdered
+func (m *Msg) MarshalBytesOrdered(order []OptionCode) ([]byte, error) {
+	var b bytes.Buffer
+	return m.marshalTo(&b, order, 0)
+}
+
+// convert a Msg structure to the network representation, making
+// sure that the result is no longer than limit bytes. if the options
+// don't fit, they overflow into the file and then the sname fields
+// (if these are empty) and the option overload option is set, as
+// described in RFC2131 chapter 4.1. an error is returned if the
+// options don't fit even then
+func (m *Msg) MarshalBytesLimit(limit int, order []OptionCode) ([]byte, error) {
 	var b bytes.Buffer
+	return m.marshalTo(&b, order, limit)
+}
+
+// write the network representation of m to b, returning the
+// bytes of the complete message. if limit is greater than
+// zero, the options are overloaded to fit within it
+func (m *Msg) marshalTo(b *bytes.Buffer, order []OptionCode, limit int) ([]byte, error) {
+	// an option 52 already in m says nothing about the message being
+	// built, so it is replaced by one which does, or left out
+	var opts, file, sname []byte
+	_, stale := m.Options[OptionOverload]
+	if limit > 0 && (stale || 240+m.Options.size()+1 > limit) {
+		var err error
+		opts, file, sname, err = m.overload(order, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if file == nil {
+		file = []byte(m.File)
+	}
+	if sname == nil {
+		sname = []byte(m.Sname)
+	}
+
 	b.Grow(272) // min size
 
 	b.WriteByte(m.Op)
@@ -92,46 +342,131 @@ func (m *Msg) MarshalBytes() []byte {
 	b.WriteByte(m.Hlen)
 	b.WriteByte(m.Hops)
 
-	binary.Write(&b, binary.BigEndian, m.Xid)
-	binary.Write(&b, binary.BigEndian, m.Secs)
-	binary.Write(&b, binary.BigEndian, m.Flags)
+	binary.Write(b, binary.BigEndian, m.Xid)
+	binary.Write(b, binary.BigEndian, m.Secs)
+	binary.Write(b, binary.BigEndian, m.Flags)
 
-	b.Write(m.Ciaddr.To4())
-	b.Write(m.Yiaddr.To4())
-	b.Write(m.Siaddr.To4())
-	b.Write(m.Giaddr.To4())
+	for _, f := range []struct {
+		name string
+		ip   net.IP
+	}{
+		{"ciaddr", m.Ciaddr},
+		{"yiaddr", m.Yiaddr},
+		{"siaddr", m.Siaddr},
+		{"giaddr", m.Giaddr},
+	} {
+		err := writeIPv4(b, f.ip)
+		if err != nil {
+			return nil, errors.Wrap(err, f.name)
+		}
+	}
 
-	// pad to 16 bytes
-	chaddr := make([]byte, 16)
-	copy(chaddr, m.Chaddr)
-	b.Write(chaddr)
+	writePadded(b, m.Chaddr, 16)
+	writePadded(b, sname, 64)
+	writePadded(b, file, 128)
 
-	// pad to 64 bytes
-	sname := make([]byte, 64)
-	copy(sname, m.Sname)
-	b.Write(sname)
+	binary.Write(b, binary.BigEndian, Cookie)
+	if opts != nil {
+		b.Write(opts)
+	} else {
+		if err := m.Options.marshalTo(b, order); err != nil {
+			return nil, err
+		}
+	}
 
-	// pad to 128 bytes
-	file := make([]byte, 128)
-	copy(file, m.File)
-	b.Write(file)
+	// pad out msg to at least 272
+	for b.Len() < 272 {
+		b.WriteByte(0)
+	}
 
-	binary.Write(&b, binary.BigEndian, Cookie)
-	b.Write(m.Options.MarshalBytes())
+	return b.Bytes(), nil
+}
 
-	// pad out msg to at least 272
-	if b.Len() < 272 {
-		padding := make([]byte, 272)
-		copy(padding, b.Bytes())
-		return padding
+// split the options of m between the options field and the file and
+// sname fields (when they are not in use) so that the message is no
+// longer than limit, returning the encoded contents of each field
+func (m *Msg) overload(order []OptionCode, limit int) (opts, file, sname []byte, err error) {
+	// the options field must also hold the overload option and
+	// the end option, unless the options fit without overloading.
+	// the other fields only need the end option. fields already in
+	// use are not available for options
+	size := m.Options.size()
+	if v, ok := m.Options[OptionOverload]; ok {
+		size -= 2 + len(v)
+	}
+	caps := [3]int{limit - 240 - 4, -1, -1}
+	if 240+size+1 <= limit {
+		caps[0] = limit - 240 - 1
+	}
+	if m.File == "" {
+		caps[1] = 128 - 1
+	}
+	if m.Sname == "" {
+		caps[2] = 64 - 1
 	}
 
-	// pad out msg to at least 272
-	if b.Len() < 272 {
-		padding := make([]byte, 272)
-		copy(padding, b.Bytes())
-		return padding
+	// fill each area in turn, keeping the options in order
+	var areas [3]bytes.Buffer
+	a := 0
+	for _, k := range m.Options.keys(order) {
+		if k == OptionOverload {
+			continue
+		}
+		v := m.Options[k]
+		for a < len(areas) && areas[a].Len()+2+len(v) > caps[a] {
+			a++
+		}
+		if a == len(areas) {
+			return nil, nil, nil, errors.Errorf(
+				"options do not fit in a message of %d bytes", limit)
+		}
+		if err := writeOption(&areas[a], k, v); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	return b.Bytes()
+	var overload byte
+	if areas[1].Len() == 0 && areas[2].Len() == 0 {
+		areas[0].WriteByte(byte(OptionEnd))
+		return areas[0].Bytes(), nil, nil, nil
+	}
+	if areas[1].Len() > 0 {
+		overload |= 1
+		areas[1].WriteByte(byte(OptionEnd))
+		file = areas[1].Bytes()
+	}
+	if areas[2].Len() > 0 {
+		overload |= 2
+		areas[2].WriteByte(byte(OptionEnd))
+		sname = areas[2].Bytes()
+	}
+	writeOption(&areas[0], OptionOverload, []byte{overload})
+	areas[0].WriteByte(byte(OptionEnd))
+
+	return areas[0].Bytes(), file, sname, nil
+}
+
+// write the 4-byte form of ip to b, or 0.0.0.0 if ip is nil
+func writeIPv4(b *bytes.Buffer, ip net.IP) error {
+	if ip == nil {
+		b.Write([]byte{0, 0, 0, 0})
+		return nil
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return errors.Wrapf(ErrNotIPv4, "%s", ip)
+	}
+	b.Write(ip4)
+	return nil
+}
+
+// write data to b, truncated or padded with zeroes to exactly n bytes
+func writePadded(b *bytes.Buffer, data []byte, n int) {
+	if len(data) > n {
+		data = data[:n]
+	}
+	b.Write(data)
+	for i := len(data); i < n; i++ {
+		b.WriteByte(0)
+	}
 }
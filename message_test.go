@@ -2,7 +2,9 @@ package jdhcp
 
 import (
 	"bytes"
+	"encoding/binary"
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	"net"
 	"testing"
 )
@@ -72,10 +74,287 @@ func TestParseMsg(t *testing.T) {
 
 func TestMsgMarshalBytes(t *testing.T) {
 	for i, tc := range messageParseCases {
-		got := tc.asStruct.MarshalBytes()
+		got, err := tc.asStruct.MarshalBytes()
+		if err != nil {
+			t.Errorf("case %d returned error: %s", i, err)
+			continue
+		}
 
 		if bytes.Compare(tc.asBytes, got) != 0 {
 			t.Errorf("case %d expected %v got %v", i, tc.asBytes, got)
 		}
 	}
 }
+
+func TestParseMsgPartial(t *testing.T) {
+	for i, tc := range messageParseCases {
+		got, problems := ParseMsgPartial(tc.asBytes)
+		if len(problems) != 0 {
+			t.Errorf("case %d returned problems: %v", i, problems)
+			continue
+		}
+
+		if diff := cmp.Diff(tc.asStruct, got); diff != "" {
+			t.Errorf("case %d expected struct does not match result: %s", i, diff)
+		}
+	}
+}
+
+func TestParseMsgPartialMalformed(t *testing.T) {
+	data := make([]byte, len(messageParseCases[0].asBytes))
+	copy(data, messageParseCases[0].asBytes)
+	data[236] = 0x00 // corrupt the cookie
+
+	// cut off in the middle of the client ID option
+	got, problems := ParseMsgPartial(data[:260])
+	if len(problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", problems)
+	}
+
+	if got.Xid != 0x00003D1D {
+		t.Errorf("incorrect xid, expected %x got %x", 0x00003D1D, got.Xid)
+	}
+	if _, ok := got.Options[OptionDHCPMessageType]; !ok {
+		t.Errorf("message type option missing from partial result")
+	}
+	if id := got.Options[OptionClientID]; len(id) != 3 {
+		t.Errorf("expected truncated client ID of 3 bytes, got %v", id)
+	}
+}
+
+func TestParseMsgPartialShort(t *testing.T) {
+	got, problems := ParseMsgPartial(messageParseCases[0].asBytes[:8])
+	if len(problems) == 0 {
+		t.Fatalf("expected problems for short message")
+	}
+	if got.Xid != 0x00003D1D {
+		t.Errorf("incorrect xid, expected %x got %x", 0x00003D1D, got.Xid)
+	}
+}
+
+func TestMsgMarshalBytesIPv6(t *testing.T) {
+	msg := NewMsg()
+	msg.Yiaddr = net.ParseIP("2001:db8::1")
+
+	_, err := msg.MarshalBytes()
+	if errors.Cause(err) != ErrNotIPv4 {
+		t.Fatalf("expected ErrNotIPv4, got %v", err)
+	}
+}
+
+func TestMsgMarshalBytesNilAddress(t *testing.T) {
+	msg := NewMsg()
+	msg.Ciaddr = nil
+
+	got, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("returned error: %s", err)
+	}
+	if bytes.Compare(got[12:16], []byte{0, 0, 0, 0}) != 0 {
+		t.Fatalf("expected zero ciaddr, got %v", got[12:16])
+	}
+}
+
+func TestMsgMarshalBytesTooLong(t *testing.T) {
+	msg := NewMsg()
+	msg.Options[OptionRouter] = make([]byte, 256)
+
+	if _, err := msg.MarshalBytes(); err == nil {
+		t.Fatalf("expected error for a value of 256 bytes")
+	}
+	if _, err := msg.MarshalBytesLimit(1500, nil); err == nil {
+		t.Fatalf("expected error for a value of 256 bytes with a limit")
+	}
+}
+
+func TestMsgSetAddresses(t *testing.T) {
+	msg := NewMsg()
+	a1 := net.IPv4(192, 168, 1, 10)
+
+	for i, set := range []func(net.IP) error{
+		msg.SetCiaddr, msg.SetYiaddr, msg.SetSiaddr, msg.SetGiaddr,
+	} {
+		err := set(a1)
+		if err != nil {
+			t.Errorf("case %d returned error: %s", i, err)
+		}
+		err = set(net.ParseIP("2001:db8::1"))
+		if errors.Cause(err) != ErrNotIPv4 {
+			t.Errorf("case %d expected ErrNotIPv4, got %v", i, err)
+		}
+	}
+
+	for i, got := range []net.IP{msg.Ciaddr, msg.Yiaddr, msg.Siaddr, msg.Giaddr} {
+		if len(got) != 4 || !a1.Equal(got) {
+			t.Errorf("case %d expected %s got %v", i, a1, got)
+		}
+	}
+}
+
+func TestMsgSetChaddr(t *testing.T) {
+	msg := NewMsg()
+	hw := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}
+
+	err := msg.SetChaddr(hw)
+	if err != nil {
+		t.Fatalf("returned error: %s", err)
+	}
+	if msg.Hlen != 8 || bytes.Compare(msg.Chaddr, hw) != 0 {
+		t.Fatalf("expected hlen 8 and chaddr %s, got %d and %s", hw, msg.Hlen, msg.Chaddr)
+	}
+
+	err = msg.SetChaddr(make(net.HardwareAddr, 17))
+	if err == nil {
+		t.Fatalf("expected error for oversize chaddr")
+	}
+}
+
+func TestMsgSetChaddrRoundTrip(t *testing.T) {
+	for _, n := range []int{1, 8, 16} {
+		msg := NewMsg()
+		hw := make(net.HardwareAddr, n)
+		for i := range hw {
+			hw[i] = byte(i + 1)
+		}
+		if err := msg.SetChaddr(hw); err != nil {
+			t.Fatalf("%d bytes: returned error: %s", n, err)
+		}
+		b, err := msg.MarshalBytes()
+		if err != nil {
+			t.Fatalf("%d bytes: cannot marshal: %s", n, err)
+		}
+		got, err := ParseMsg(b)
+		if err != nil {
+			t.Fatalf("%d bytes: cannot parse: %s", n, err)
+		}
+		if got.Hlen != byte(n) || !bytes.Equal(got.Chaddr, hw) {
+			t.Errorf("%d bytes: expected chaddr %s got hlen %d and %s", n, hw, got.Hlen, got.Chaddr)
+		}
+	}
+}
+
+func TestMsgMarshalBytesLimit(t *testing.T) {
+	msg := NewMsg()
+	msg.Options[OptionDHCPMessageType] = []byte{byte(Offer)}
+	msg.Options[OptionCode(43)] = make([]byte, 200)
+	msg.Options[OptionCode(100)] = bytes.Repeat([]byte{0x01}, 100)
+	msg.Options[OptionCode(101)] = bytes.Repeat([]byte{0x02}, 60)
+
+	// everything fits without a limit
+	got, err := msg.MarshalBytesLimit(0, nil)
+	if err != nil {
+		t.Fatalf("returned error: %s", err)
+	}
+	if len(got) != 240+3+202+102+62+1 {
+		t.Fatalf("unexpected length %d", len(got))
+	}
+
+	got, err = msg.MarshalBytesLimit(548, nil)
+	if err != nil {
+		t.Fatalf("returned error: %s", err)
+	}
+	if len(got) > 548 {
+		t.Fatalf("message of %d bytes exceeds limit", len(got))
+	}
+
+	opts, err := ParseOptions(got[240:])
+	if err != nil {
+		t.Fatalf("could not parse options field: %s", err)
+	}
+	if bytes.Compare(opts[OptionOverload], []byte{3}) != 0 {
+		t.Errorf("expected overload of 3, got %v", opts[OptionOverload])
+	}
+	if got[108] != 100 || got[108+102] != byte(OptionEnd) {
+		t.Errorf("expected option 100 in file field, got %v", got[108:236])
+	}
+	if got[44] != 101 || got[44+62] != byte(OptionEnd) {
+		t.Errorf("expected option 101 in sname field, got %v", got[44:108])
+	}
+
+	// the options are put back together when parsed. ParseMsg
+	// does not accept the hlen of 0 left by NewMsg
+	got[2] = 6
+	res, err := ParseMsg(got)
+	if err != nil {
+		t.Fatalf("could not parse overloaded message: %s", err)
+	}
+	if _, ok := res.Options[OptionOverload]; ok || len(res.Options) != 4 ||
+		!bytes.Equal(res.Options[OptionCode(100)], msg.Options[OptionCode(100)]) ||
+		!bytes.Equal(res.Options[OptionCode(101)], msg.Options[OptionCode(101)]) {
+		t.Errorf("unexpected options after parsing %v", res.Options)
+	}
+	if res.File != "" || res.Sname != "" {
+		t.Errorf("expected empty file and sname, got %q and %q", res.File, res.Sname)
+	}
+	res, problems := ParseMsgPartial(got)
+	if len(problems) != 0 || len(res.Options) != 4 {
+		t.Errorf("unexpected partial parse %v, problems %v", res.Options, problems)
+	}
+
+	// with the file field in use, there is no longer enough room
+	msg.File = "pxelinux.0"
+	_, err = msg.MarshalBytesLimit(548, nil)
+	if err == nil {
+		t.Fatalf("expected error when options do not fit")
+	}
+}
+
+func TestMsgMarshalBytesLimitStaleOverload(t *testing.T) {
+	msg := NewMsg()
+	msg.Options[OptionDHCPMessageType] = []byte{byte(Offer)}
+	// 307 bytes of options including the end option, which only
+	// exceeds the limit because of the stale option 52
+	msg.Options[OptionCode(43)] = make([]byte, 255)
+	msg.Options[OptionCode(100)] = make([]byte, 44)
+	msg.Options[OptionOverload] = []byte{3}
+
+	for _, limit := range []int{548, 1500} {
+		got, err := msg.MarshalBytesLimit(limit, nil)
+		if err != nil {
+			t.Fatalf("limit %d: returned error: %s", limit, err)
+		}
+		if len(got) > limit {
+			t.Errorf("limit %d: message of %d bytes exceeds limit", limit, len(got))
+		}
+		opts, err := ParseOptions(got[240:])
+		if err != nil {
+			t.Fatalf("limit %d: could not parse options field: %s", limit, err)
+		}
+		if v, ok := opts[OptionOverload]; ok {
+			t.Errorf("limit %d: expected no overload option, got %v", limit, v)
+		}
+		if len(opts) != 3 {
+			t.Errorf("limit %d: unexpected options %v", limit, opts)
+		}
+		if got[108] != 0 || got[44] != 0 {
+			t.Errorf("limit %d: expected empty file and sname fields", limit)
+		}
+	}
+}
+
+func TestParseMsgOverload(t *testing.T) {
+	data := make([]byte, 240, 300)
+	data[2] = 6
+	binary.BigEndian.PutUint32(data[236:240], Cookie)
+	// option 12 split between the options field and the file field
+	data = append(data, 52, 1, 1, 53, 1, 1, 12, 2, 'a', 'b', 255)
+	copy(data[108:], []byte{12, 2, 'c', 'd', 255})
+	copy(data[44:], "server")
+
+	msg, err := ParseMsg(data)
+	if err != nil {
+		t.Fatalf("ParseMsg() returned error: %s", err)
+	}
+	if string(msg.Options[OptionHostName]) != "abcd" {
+		t.Errorf("expected host name abcd, got %q", msg.Options[OptionHostName])
+	}
+	// only the file field is overloaded
+	if msg.Sname != "server" || msg.File != "" {
+		t.Errorf("unexpected sname %q and file %q", msg.Sname, msg.File)
+	}
+
+	data[242] = 4
+	if _, err := ParseMsg(data); err == nil {
+		t.Errorf("expected error for an invalid overload value")
+	}
+}
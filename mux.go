@@ -0,0 +1,130 @@
+package jdhcp
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// a Mux passes each message to the handler for the network it came
+// from, so that one Server can serve several isolated tenants, each
+// with its own addresses and options. networks are identified by
+// their Virtual Subnet Selection (a VPN or VRF, from option 221 or the
+// VSS sub-option of option 82), or by the subnet of the relay, or by
+// the subnet asked for with option 118 or the link selection
+// sub-option of option 82, or by the interface the message arrived on.
+// messages on other networks go to Default, which may be nil.
+//
+// the interface is only known where the Server reports it with
+// InterfaceIndexFromContext. there is no key for the VLAN tag, as it
+// is removed before the message reaches a UDP socket: each VLAN is
+// served through its own interface (such as eth0.100) instead.
+//
+// register its Handle method with Server.RegisterHandler. handlers
+// can be added while it is in use, but Default should be set first
+type Mux struct {
+	Default MsgHandler
+
+	mu     sync.RWMutex
+	vss    map[string]MsgHandler
+	relays []muxRelay
+	ifaces map[string]MsgHandler
+}
+
+type muxRelay struct {
+	subnet  *net.IPNet
+	handler MsgHandler
+}
+
+// create an empty Mux
+func NewMux() *Mux {
+	return &Mux{vss: make(map[string]MsgHandler), ifaces: make(map[string]MsgHandler)}
+}
+
+// use h for messages with the given Virtual Subnet Selection, which
+// is the value of the option including its type byte
+func (m *Mux) HandleVSS(vss []byte, h MsgHandler) {
+	m.mu.Lock()
+	m.vss[string(vss)] = h
+	m.mu.Unlock()
+}
+
+// use h for messages relayed from a giaddr in subnet, or asking for
+// an address in subnet. subnets are checked in the order they were added
+func (m *Mux) HandleRelay(subnet *net.IPNet, h MsgHandler) {
+	m.mu.Lock()
+	m.relays = append(m.relays, muxRelay{subnet, h})
+	m.mu.Unlock()
+}
+
+// use h for messages which arrived on the interface with the given
+// name, such as a VLAN interface
+func (m *Mux) HandleInterface(name string, h MsgHandler) {
+	m.mu.Lock()
+	m.ifaces[name] = h
+	m.mu.Unlock()
+}
+
+// pass req to the handler for its network
+func (m *Mux) Handle(ctx context.Context, req Msg) *Msg {
+	h := m.handler(ctx, &req)
+	if h == nil {
+		return nil
+	}
+	return h(ctx, req)
+}
+
+// find the handler for req, a VSS match is preferred over a relay
+// one, which is preferred over an interface one
+func (m *Mux) handler(ctx context.Context, req *Msg) MsgHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if vss, ok := requestVSS(req); ok {
+		if h, ok := m.vss[string(vss)]; ok {
+			return h
+		}
+	}
+	if link := requestLink(req); link != nil {
+		for _, r := range m.relays {
+			if r.subnet.Contains(link) {
+				return r.handler
+			}
+		}
+	}
+	if i := InterfaceIndexFromContext(ctx); i > 0 && len(m.ifaces) > 0 {
+		if ifi, err := net.InterfaceByIndex(i); err == nil {
+			if h, ok := m.ifaces[ifi.Name]; ok {
+				return h
+			}
+		}
+	}
+	return m.Default
+}
+
+// get the address of the subnet req asks for an address on. the
+// subnet selection option takes precedence over the link selection
+// sub-option of option 82 (RFC3527 chapter 4), which takes precedence
+// over giaddr. nil if the request is not relayed and asks for none
+func requestLink(req *Msg) net.IP {
+	if ip, err := req.Options.SubnetSelection(); err == nil {
+		return ip
+	}
+	if rai, err := req.Options.RelayAgentInfo(); err == nil && rai.LinkSelection != nil {
+		return rai.LinkSelection
+	}
+	if req.Giaddr != nil && !req.Giaddr.IsUnspecified() {
+		return req.Giaddr
+	}
+	return nil
+}
+
+// get the Virtual Subnet Selection of req. the one added by a relay
+// takes precedence over one from the client (RFC6607 chapter 6)
+func requestVSS(req *Msg) ([]byte, bool) {
+	if rai, err := req.Options.RelayAgentInfo(); err == nil && rai.VSS != nil {
+		return rai.VSS, true
+	}
+	vss, ok := req.Options[OptionVirtualSubnetSelection]
+	return vss, ok
+}
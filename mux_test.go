@@ -0,0 +1,106 @@
+package jdhcp
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	// each handler answers with its own xid
+	handler := func(xid uint32) MsgHandler {
+		return func(_ context.Context, req Msg) *Msg {
+			res := NewMsg()
+			res.Xid = xid
+			return res
+		}
+	}
+
+	mux := NewMux()
+	mux.HandleVSS([]byte{0, 'r', 'e', 'd'}, handler(1))
+	mux.HandleVSS([]byte{0, 'b', 'l', 'u', 'e'}, handler(2))
+	mux.HandleRelay(mustCIDR("10.1.0.0/16"), handler(3))
+
+	for i, tc := range []struct {
+		opts   Options
+		giaddr net.IP
+		xid    uint32 // 0 for no response
+	}{
+		// 0 vss from the client
+		{Options{OptionVirtualSubnetSelection: {0, 'r', 'e', 'd'}}, net.IPv4zero, 1},
+		// 1 vss from the relay wins
+		{Options{
+			OptionVirtualSubnetSelection: {0, 'r', 'e', 'd'},
+			OptionRelayAgentInformation:  {1, 1, 'x', 151, 5, 0, 'b', 'l', 'u', 'e'},
+		}, net.IPv4(10, 1, 0, 1), 2},
+		// 2 relay subnet
+		{Options{}, net.IPv4(10, 1, 2, 3), 3},
+		// 3 unknown vss falls back to the relay
+		{Options{OptionVirtualSubnetSelection: {0, 'x'}}, net.IPv4(10, 1, 2, 3), 3},
+		// 4 no default
+		{Options{}, net.IPv4(10, 2, 0, 1), 0},
+		// 5 subnet selection wins over giaddr
+		{Options{OptionSubnetSelection: {10, 1, 0, 0}}, net.IPv4(10, 2, 0, 1), 3},
+		// 6 link selection from the relay
+		{Options{OptionRelayAgentInformation: {5, 4, 10, 1, 0, 0}}, net.IPv4(10, 2, 0, 1), 3},
+	} {
+		req := NewMsg()
+		req.Options = tc.opts
+		req.Giaddr = tc.giaddr
+
+		res := mux.Handle(context.Background(), *req)
+		if tc.xid == 0 {
+			if res != nil {
+				t.Errorf("case %d: expected no response, got xid %d", i, res.Xid)
+			}
+			continue
+		}
+		if res == nil || res.Xid != tc.xid {
+			t.Errorf("case %d: expected handler %d, got %+v", i, tc.xid, res)
+		}
+	}
+
+	mux.Default = handler(4)
+	res := mux.Handle(context.Background(), *NewMsg())
+	if res == nil || res.Xid != 4 {
+		t.Errorf("expected default handler, got %+v", res)
+	}
+}
+
+func TestMuxInterface(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	handler := func(xid uint32) MsgHandler {
+		return func(_ context.Context, req Msg) *Msg {
+			res := NewMsg()
+			res.Xid = xid
+			return res
+		}
+	}
+
+	mux := NewMux()
+	mux.HandleRelay(mustCIDR("10.1.0.0/16"), handler(1))
+	mux.HandleInterface("lo", handler(2))
+	ctx := context.WithValue(context.Background(), ifindexKey{}, lo.Index)
+
+	// a direct client on lo
+	res := mux.Handle(ctx, *NewMsg())
+	if res == nil || res.Xid != 2 {
+		t.Errorf("expected interface handler, got %+v", res)
+	}
+
+	// the relay subnet is preferred over the interface
+	req := NewMsg()
+	req.Giaddr = net.IPv4(10, 1, 2, 3)
+	res = mux.Handle(ctx, *req)
+	if res == nil || res.Xid != 1 {
+		t.Errorf("expected relay handler, got %+v", res)
+	}
+
+	// the interface is not known
+	if res := mux.Handle(context.Background(), *NewMsg()); res != nil {
+		t.Errorf("expected no response, got xid %d", res.Xid)
+	}
+}
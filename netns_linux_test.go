@@ -0,0 +1,207 @@
+package jdhcp
+
+// helpers to build a private network out of network namespaces and
+// a veth pair, so the broadcast paths of the Server can be exercised
+// end to end. these need root (or CAP_NET_ADMIN and CAP_SYS_ADMIN)
+// and the ip command, tests using them are skipped otherwise.
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var netnsCount int32
+
+// the syscall package does not define SYS_SETNS on all platforms
+var sysSetns = map[string]uintptr{
+	"386":     346,
+	"amd64":   308,
+	"arm":     375,
+	"arm64":   268,
+	"ppc64le": 350,
+	"riscv64": 268,
+	"s390x":   339,
+}
+
+// a testLink is a veth pair connecting two network namespaces.
+// the server end has ServerAddr/24 assigned, the client end is up
+// but has no address, like the interface of a new DHCP client
+type testLink struct {
+	ServerNS, ClientNS       string
+	ServerIface, ClientIface string
+	ServerAddr               net.IP
+}
+
+// create a testLink which is removed when the test finishes
+func newTestLink(t *testing.T) *testLink {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("network namespace tests need root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("network namespace tests need the ip command")
+	}
+	if _, ok := sysSetns[runtime.GOARCH]; !ok {
+		t.Skipf("setns is not known on %s", runtime.GOARCH)
+	}
+
+	n := atomic.AddInt32(&netnsCount, 1)
+	id := fmt.Sprintf("%d-%d", os.Getpid()%10000, n)
+	l := &testLink{
+		ServerNS:    "jdhcp-s" + id,
+		ClientNS:    "jdhcp-c" + id,
+		ServerIface: "jds" + id,
+		ClientIface: "jdc" + id,
+		ServerAddr:  net.IPv4(10, 67, byte(n), 1).To4(),
+	}
+
+	t.Cleanup(func() {
+		// removing the namespaces also removes the veth pair
+		exec.Command("ip", "netns", "del", l.ServerNS).Run()
+		exec.Command("ip", "netns", "del", l.ClientNS).Run()
+	})
+
+	for _, args := range [][]string{
+		{"netns", "add", l.ServerNS},
+		{"netns", "add", l.ClientNS},
+		{"link", "add", l.ServerIface, "netns", l.ServerNS,
+			"type", "veth", "peer", "name", l.ClientIface, "netns", l.ClientNS},
+		{"-n", l.ServerNS, "addr", "add", l.ServerAddr.String() + "/24", "dev", l.ServerIface},
+		{"-n", l.ServerNS, "link", "set", l.ServerIface, "up"},
+		{"-n", l.ClientNS, "link", "set", l.ClientIface, "up"},
+		// limited broadcasts need a route in both namespaces
+		{"-n", l.ServerNS, "route", "add", "255.255.255.255", "dev", l.ServerIface},
+		{"-n", l.ClientNS, "route", "add", "255.255.255.255", "dev", l.ClientIface},
+	} {
+		out, err := exec.Command("ip", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("ip %v failed: %s: %s", args, err, out)
+		}
+	}
+
+	return l
+}
+
+// run fn with the calling goroutine in the network namespace ns.
+// sockets opened by fn belong to ns for as long as they are open
+func inNetns(t *testing.T, ns string, fn func()) {
+	t.Helper()
+	runtime.LockOSThread()
+
+	orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("could not open current netns: %s", err)
+	}
+	defer orig.Close()
+
+	target, err := os.Open("/var/run/netns/" + ns)
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("could not open netns %s: %s", ns, err)
+	}
+	defer target.Close()
+
+	err = setns(target)
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("could not enter netns %s: %s", ns, err)
+	}
+
+	defer func() {
+		// if we can't get back, leave the thread locked so
+		// that it is thrown away rather than reused
+		if setns(orig) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	fn()
+}
+
+func setns(f *os.File) error {
+	_, _, errno := syscall.RawSyscall(sysSetns[runtime.GOARCH], f.Fd(), syscall.CLONE_NEWNET, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// check that a client without an address gets a broadcast reply
+// from a Server on the wildcard address
+func TestNetnsBroadcastReply(t *testing.T) {
+	link := newTestLink(t)
+
+	var serv *Server
+	inNetns(t, link.ServerNS, func() {
+		serv = NewServer(WithLogger(testLogg))
+		serv.RegisterCallback(func(req Msg) *Msg {
+			res := NewMsg()
+			res.Op = 2
+			res.Htype = req.Htype
+			res.Hlen = req.Hlen
+			res.Xid = req.Xid
+			res.Chaddr = req.Chaddr
+			res.Yiaddr = net.IPv4(10, 67, 0, 100)
+			res.Options[OptionDHCPMessageType] = []byte{byte(Offer)}
+			res.Options[OptionServerIdentifier] = link.ServerAddr
+			return res
+		})
+		err := serv.Start()
+		if err != nil {
+			t.Fatalf("could not start server: %s", err)
+		}
+	})
+	defer serv.Stop()
+
+	var conn *net.UDPConn
+	inNetns(t, link.ClientNS, func() {
+		var err error
+		conn, err = net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: ClientPort})
+		if err != nil {
+			t.Fatalf("could not open client socket: %s", err)
+		}
+	})
+	defer conn.Close()
+
+	msg := NewMsg()
+	msg.Op = 1
+	msg.Htype = 1
+	msg.Hlen = 6
+	msg.Xid = 0x12345678
+	msg.Chaddr = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	msg.Options[OptionDHCPMessageType] = []byte{byte(Discover)}
+
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	_, err = conn.WriteToUDP(payload, &net.UDPAddr{IP: net.IPv4bcast, Port: ServerPort})
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("did not receive a reply: %s", err)
+	}
+
+	res, err := ParseMsg(buf[:n])
+	if err != nil {
+		t.Fatalf("could not parse reply: %s", err)
+	}
+	if res.Xid != msg.Xid {
+		t.Errorf("reply has wrong xid, expected %x got %x", msg.Xid, res.Xid)
+	}
+	if mt, _ := res.DHCPMessageType(); mt != Offer {
+		t.Errorf("reply has wrong type, expected %d got %d", Offer, mt)
+	}
+}
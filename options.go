@@ -3,9 +3,13 @@ package jdhcp
 import (
 	"bytes"
 	"encoding/binary"
+	"github.com/pkg/errors"
 	"io"
+	"math"
 	"net"
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -23,8 +27,18 @@ type Options map[OptionCode][]byte
 
 func ParseOptions(data []byte) (Options, error) {
 	opts := make(Options)
+	err := parseOptionsInto(opts, data, ParseLimits{})
+	return opts, err
+}
+
+// parse the options in data and add them to opts, checking them
+// against limits. the values stored in opts will refer to the
+// contents of data. errors are returned as a *ParseError
+func parseOptionsInto(opts Options, data []byte, limits ParseLimits) error {
 	buf := bytes.NewBuffer(data)
 
+	// only options with a code and length are counted, not Pad
+	count := 0
 	for {
 		// read code
 		code, err := buf.ReadByte()
@@ -32,7 +46,7 @@ func ParseOptions(data []byte) (Options, error) {
 			if err == io.EOF {
 				break
 			}
-			return opts, err
+			return err
 		}
 
 		// handle options without length
@@ -49,37 +63,174 @@ func ParseOptions(data []byte) (Options, error) {
 			if err == io.EOF {
 				break
 			}
-			return opts, err
+			return err
+		}
+
+		count++
+		err = limits.checkOption(count, OptionCode(code), int(l))
+		if err != nil {
+			return &ParseError{Offset: len(data) - buf.Len() - 2, Field: "options", Err: err}
 		}
 
 		opts[OptionCode(code)] = buf.Next(int(l))
 	}
-	return opts, nil
+	return nil
+}
+
+// parse as many options as possible from data, returning a list of
+// the problems encountered alongside the options which were decoded.
+// a truncated option is included with the bytes that were available
+func ParseOptionsPartial(data []byte) (Options, []error) {
+	opts := make(Options)
+	var problems []error
+
+	i := 0
+	for i < len(data) {
+		code := OptionCode(data[i])
+		i++
+
+		// handle options without length
+		if code == OptionEnd {
+			return opts, problems
+		}
+		if code == OptionPad {
+			continue
+		}
+
+		if i >= len(data) {
+			problems = append(problems, errors.Wrapf(ErrShortRead,
+				"option %d has no length", code))
+			return opts, problems
+		}
+		l := int(data[i])
+		i++
+
+		if i+l > len(data) {
+			problems = append(problems, errors.Wrapf(ErrShortRead,
+				"option %d truncated, expected %d bytes got %d",
+				code, l, len(data)-i))
+			l = len(data) - i
+		}
+		if _, ok := opts[code]; ok {
+			problems = append(problems, errors.Errorf(
+				"option %d appears more than once", code))
+		}
+
+		opts[code] = data[i : i+l]
+		i += l
+	}
+
+	problems = append(problems, errors.New("options not terminated by end option"))
+	return opts, problems
 }
 
 // convert to a []byte suitable for sending over the wire
 // sort options before sending so that the result is deterministic
-func (o Options) MarshalBytes() []byte {
-	// first get all keys and sort them
-	ks := make([]OptionCode, 0, len(o))
-	for k, _ := range o {
-		ks = append(ks, k)
+// an error is returned if a value is longer than 255 bytes
+func (o Options) MarshalBytes() ([]byte, error) {
+	var b bytes.Buffer
+	if err := o.marshalTo(&b, nil); err != nil {
+		return nil, err
 	}
-	sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+	return b.Bytes(), nil
+}
 
-	// write out the TLVs as bytes
+// convert to a []byte suitable for sending over the wire, writing
+// the options listed in order first (in that order) followed by
+// any remaining options sorted numerically
+func (o Options) MarshalBytesOrdered(order []OptionCode) ([]byte, error) {
 	var b bytes.Buffer
-	for _, k := range ks {
-		b.WriteByte(byte(k))
-		b.WriteByte(byte(len(o[k])))
-		b.Write(o[k])
+	if err := o.marshalTo(&b, order); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// write the options as TLVs to b, followed by OptionEnd. the
+// options in order are written first, then the rest by code
+func (o Options) marshalTo(b *bytes.Buffer, order []OptionCode) error {
+	for _, k := range o.keys(order) {
+		if err := writeOption(b, k, o[k]); err != nil {
+			return err
+		}
 	}
 	b.WriteByte(byte(OptionEnd))
+	return nil
+}
 
-	return b.Bytes()
+// get the codes of all options present, with those in order
+// first (in that order) and the remaining ones sorted by code
+func (o Options) keys(order []OptionCode) []OptionCode {
+	ks := make([]OptionCode, 0, len(o))
+
+	var seen [256]bool
+	for _, k := range order {
+		if _, ok := o[k]; ok && !seen[k] {
+			ks = append(ks, k)
+			seen[k] = true
+		}
+	}
+	n := len(ks)
+
+	// get all remaining keys and sort them
+	for k, _ := range o {
+		if !seen[k] {
+			ks = append(ks, k)
+		}
+	}
+	rest := ks[n:]
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+
+	return ks
 }
 
-// insert an option to the set
+// get the number of bytes needed to encode the options as TLVs,
+// not including OptionEnd
+func (o Options) size() int {
+	n := 0
+	for _, v := range o {
+		n += 2 + len(v)
+	}
+	return n
+}
+
+// write a single option as a TLV. the length is a single byte,
+// so a longer value would corrupt the options that follow it
+func writeOption(b *bytes.Buffer, k OptionCode, v []byte) error {
+	if len(v) > 255 {
+		return &OptionError{k, errors.Errorf("length %d is more than 255", len(v))}
+	}
+	b.WriteByte(byte(k))
+	b.WriteByte(byte(len(v)))
+	b.Write(v)
+	return nil
+}
+
+// OptionOrder selects the order in which the Server writes
+// the options of a response
+type OptionOrder int
+
+const (
+	// options are sorted numerically by code
+	OrderNumeric OptionOrder = iota
+	// the message type and server identifier come first,
+	// followed by the options in the order they appear in the
+	// parameter request list of the request, then the rest
+	OrderRequested
+)
+
+// build the order used by OrderRequested from the parameter
+// request list of a client, suitable for MarshalBytesOrdered
+func RequestedOrder(prl []OptionCode) []OptionCode {
+	order := make([]OptionCode, 0, len(prl)+2)
+	order = append(order, OptionDHCPMessageType, OptionServerIdentifier)
+	return append(order, prl...)
+}
+
+// insert an option to the set, encoding v with binary.Write. this
+// does not know the format of the option, so a time.Duration becomes
+// 8 bytes of nanoseconds rather than 4 of seconds. the Set methods of
+// the options should be used instead where there is one
 func (o Options) Insert(oc OptionCode, v interface{}) error {
 	var b bytes.Buffer
 	err := binary.Write(&b, binary.BigEndian, v)
@@ -100,6 +251,165 @@ func (o Options) SubnetMask() (net.IPMask, error) {
 	return net.IPMask(a), nil
 }
 
+// option 2, the offset of the local time zone from UTC, which is
+// negative west of the prime meridian
+func (o Options) TimeOffset() (time.Duration, error) {
+	n, err := o.GetUint32(OptionTimeOffset)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(int32(n)) * time.Second, nil
+}
+
+// set option 2, in whole seconds
+func (o Options) SetTimeOffset(d time.Duration) error {
+	s := d / time.Second
+	if s < math.MinInt32 || s > math.MaxInt32 {
+		return &OptionError{OptionTimeOffset, errors.Errorf("offset %s out of range", d)}
+	}
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, uint32(int32(s)))
+	o[OptionTimeOffset] = v
+	return nil
+}
+
+// option 3
+func (o Options) Routers() ([]net.IP, error) {
+	return o.IPs(OptionRouter)
+}
+
+// set option 3
+func (o Options) SetRouters(ips []net.IP) error {
+	return o.SetIPs(OptionRouter, ips)
+}
+
+// option 6
+func (o Options) DNSServers() ([]net.IP, error) {
+	return o.IPs(OptionDomainNameServer)
+}
+
+// set option 6
+func (o Options) SetDNSServers(ips []net.IP) error {
+	return o.SetIPs(OptionDomainNameServer, ips)
+}
+
+// option 12
+func (o Options) HostName() (string, error) {
+	return o.GetString(OptionHostName)
+}
+
+// set option 12
+func (o Options) SetHostName(name string) error {
+	return o.setStr(OptionHostName, name)
+}
+
+// option 15
+func (o Options) DomainName() (string, error) {
+	return o.GetString(OptionDomainName)
+}
+
+// set option 15
+func (o Options) SetDomainName(name string) error {
+	return o.setStr(OptionDomainName, name)
+}
+
+// option 17, the path of the root disk of a diskless client
+func (o Options) RootPath() (string, error) {
+	return o.GetString(OptionRootPath)
+}
+
+// set option 17
+func (o Options) SetRootPath(path string) error {
+	return o.setStr(OptionRootPath, path)
+}
+
+// option 26
+func (o Options) InterfaceMTU() (uint16, error) {
+	return o.GetUint16(OptionInterfaceMTU)
+}
+
+// set option 26, which must be at least 68 (RFC2132 chapter 5.1)
+func (o Options) SetInterfaceMTU(mtu uint16) error {
+	if mtu < 68 {
+		return &OptionError{OptionInterfaceMTU, errors.Errorf("mtu %d is less than 68", mtu)}
+	}
+	o.setUint16(OptionInterfaceMTU, mtu)
+	return nil
+}
+
+// option 28
+func (o Options) BroadcastAddress() (net.IP, error) {
+	return o.ip(OptionBroadcastAddress)
+}
+
+// set option 28
+func (o Options) SetBroadcastAddress(ip net.IP) error {
+	return o.setIP(OptionBroadcastAddress, ip)
+}
+
+// option 42
+func (o Options) NTPServers() ([]net.IP, error) {
+	return o.IPs(OptionNTPServers)
+}
+
+// set option 42
+func (o Options) SetNTPServers(ips []net.IP) error {
+	return o.SetIPs(OptionNTPServers, ips)
+}
+
+// option 44
+func (o Options) NetBIOSNameServers() ([]net.IP, error) {
+	return o.IPs(OptionNetBIOSNameServer)
+}
+
+// set option 44
+func (o Options) SetNetBIOSNameServers(ips []net.IP) error {
+	return o.SetIPs(OptionNetBIOSNameServer, ips)
+}
+
+// the NetBIOS node types of option 46 (RFC1001 chapter 10)
+type NetBIOSNodeType byte
+
+const (
+	NetBIOSBNode NetBIOSNodeType = 0x1 // broadcast
+	NetBIOSPNode NetBIOSNodeType = 0x2 // name server only
+	NetBIOSMNode NetBIOSNodeType = 0x4 // broadcast, then name server
+	NetBIOSHNode NetBIOSNodeType = 0x8 // name server, then broadcast
+)
+
+// option 46
+func (o Options) NetBIOSNodeType() (NetBIOSNodeType, error) {
+	v, ok := o[OptionNetBIOSNodeType]
+	if !ok {
+		return 0, ErrOptionNotPresent
+	}
+	if len(v) != 1 {
+		return 0, &OptionError{OptionNetBIOSNodeType, errors.Errorf("length %d is not 1", len(v))}
+	}
+	return NetBIOSNodeType(v[0]), nil
+}
+
+// set option 46
+func (o Options) SetNetBIOSNodeType(t NetBIOSNodeType) error {
+	switch t {
+	case NetBIOSBNode, NetBIOSPNode, NetBIOSMNode, NetBIOSHNode:
+	default:
+		return &OptionError{OptionNetBIOSNodeType, errors.Errorf("unknown node type 0x%x", byte(t))}
+	}
+	o[OptionNetBIOSNodeType] = []byte{byte(t)}
+	return nil
+}
+
+// option 47
+func (o Options) NetBIOSScope() (string, error) {
+	return o.GetString(OptionNetBIOSScope)
+}
+
+// set option 47
+func (o Options) SetNetBIOSScope(scope string) error {
+	return o.setStr(OptionNetBIOSScope, scope)
+}
+
 // option 50
 func (o Options) RequestedIPAddress() (net.IP, error) {
 	a, ok := o[OptionRequestedIPAddress]
@@ -116,12 +426,22 @@ func (o Options) DHCPMessageType() (MessageType, error) {
 		return 0, ErrOptionNotPresent
 	}
 	if len(t) < 1 {
-		return 0, ErrShortRead
+		return 0, &OptionError{OptionDHCPMessageType, ErrShortRead}
 	}
 
 	return MessageType(t[0]), nil
 }
 
+// option 54
+func (o Options) ServerID() (net.IP, error) {
+	return o.ip(OptionServerIdentifier)
+}
+
+// set option 54
+func (o Options) SetServerID(ip net.IP) error {
+	return o.setIP(OptionServerIdentifier, ip)
+}
+
 // option 55
 func (o Options) ParameterRequestList() ([]OptionCode, error) {
 	pl, ok := o[OptionParameterRequestList]
@@ -137,24 +457,112 @@ func (o Options) ParameterRequestList() ([]OptionCode, error) {
 	return ret, nil
 }
 
+// option 56, an error message sent with a DHCPNAK, or by a client
+// with a DHCPDECLINE
+func (o Options) Message() (string, error) {
+	return o.GetString(OptionMessage)
+}
+
+// set option 56
+func (o Options) SetMessage(msg string) error {
+	return o.setStr(OptionMessage, msg)
+}
+
+// option 57, the largest message including the IP and UDP headers
+func (o Options) MaxMessageSize() (uint16, error) {
+	return o.GetUint16(OptionMaximumMessageSize)
+}
+
+// set option 57, which must be at least DefaultMaxMessageSize
+func (o Options) SetMaxMessageSize(size uint16) error {
+	if size < DefaultMaxMessageSize {
+		return &OptionError{OptionMaximumMessageSize,
+			errors.Errorf("size %d is less than %d", size, DefaultMaxMessageSize)}
+	}
+	o.setUint16(OptionMaximumMessageSize, size)
+	return nil
+}
+
+// option 51
+func (o Options) IPAddressLeaseTime() (time.Duration, error) {
+	return o.duration(OptionIPAddressLeaseTime)
+}
+
+// set option 51
+func (o Options) SetIPAddressLeaseTime(d time.Duration) error {
+	return o.setDuration(OptionIPAddressLeaseTime, d)
+}
+
 // option 58
 func (o Options) RenewalTime() (time.Duration, error) {
-	d, ok := o[OptionRenewalTime]
-	if !ok {
-		return 0, ErrOptionNotPresent
-	}
+	return o.duration(OptionRenewalTime)
+}
 
-	return time.Duration(binary.BigEndian.Uint64(d)), nil
+// set option 58
+func (o Options) SetRenewalTime(d time.Duration) error {
+	return o.setDuration(OptionRenewalTime, d)
 }
 
 // option 59
 func (o Options) RebindingTime() (time.Duration, error) {
-	d, ok := o[OptionRebindingTime]
+	return o.duration(OptionRebindingTime)
+}
+
+// set option 59
+func (o Options) SetRebindingTime(d time.Duration) error {
+	return o.setDuration(OptionRebindingTime, d)
+}
+
+// InfiniteLease is the lease time which never expires, sent as
+// 0xffffffff seconds (RFC2131 chapter 3.3). it is returned and
+// accepted by the accessors of the time options in place of a duration
+const InfiniteLease time.Duration = -1
+
+// the value of a time option which means infinity
+const infiniteSeconds = 0xffffffff
+
+// get an option which holds a 4-byte number of seconds
+func (o Options) duration(code OptionCode) (time.Duration, error) {
+	v, ok := o[code]
 	if !ok {
 		return 0, ErrOptionNotPresent
 	}
+	if len(v) != 4 {
+		return 0, &OptionError{code, errors.Errorf("length %d is not 4", len(v))}
+	}
+
+	s := binary.BigEndian.Uint32(v)
+	if s == infiniteSeconds {
+		return InfiniteLease, nil
+	}
+	return time.Duration(s) * time.Second, nil
+}
+
+// set an option which holds a 4-byte number of seconds, rounding d
+// down to a whole second
+func (o Options) setDuration(code OptionCode, d time.Duration) error {
+	s := uint32(infiniteSeconds)
+	if d != InfiniteLease {
+		if d < 0 || d/time.Second >= infiniteSeconds {
+			return &OptionError{code, errors.Errorf("duration %s out of range", d)}
+		}
+		s = uint32(d / time.Second)
+	}
+
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, s)
+	o[code] = v
+	return nil
+}
 
-	return time.Duration(binary.BigEndian.Uint64(d)), nil
+// option 60
+func (o Options) VendorClassIdentifier() (string, error) {
+	return o.GetString(OptionVendorClassIdentifier)
+}
+
+// set option 60
+func (o Options) SetVendorClassIdentifier(class string) error {
+	return o.setStr(OptionVendorClassIdentifier, class)
 }
 
 // option 61
@@ -165,10 +573,495 @@ func (o Options) ClientID() (kind byte, id []byte, err error) {
 		return
 	}
 	if len(ci) < 2 {
-		err = ErrShortRead
+		err = &OptionError{OptionClientID, ErrShortRead}
 		return
 	}
 	kind = ci[0]
 	id = ci[1:]
 	return
 }
+
+// option 77, a list of user classes (RFC3004). older Windows clients
+// send a single class without a length in front, which is returned as
+// the only item when the value is not a valid list
+func (o Options) UserClass() ([][]byte, error) {
+	v, ok := o[OptionUserClass]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) == 0 {
+		return nil, &OptionError{OptionUserClass, ErrShortRead}
+	}
+
+	var classes [][]byte
+	for i := 0; i < len(v); {
+		n := int(v[i])
+		if n == 0 || i+1+n > len(v) {
+			return [][]byte{v}, nil
+		}
+		classes = append(classes, v[i+1:i+1+n])
+		i += 1 + n
+	}
+	return classes, nil
+}
+
+// set option 77 to a list of user classes
+func (o Options) SetUserClass(classes [][]byte) error {
+	if len(classes) == 0 {
+		return &OptionError{OptionUserClass, errors.New("at least one class is needed")}
+	}
+	var v []byte
+	for _, c := range classes {
+		if len(c) == 0 || len(c) > 255 {
+			return &OptionError{OptionUserClass, errors.Errorf("class length %d is not from 1 to 255", len(c))}
+		}
+		v = append(v, byte(len(c)))
+		v = append(v, c...)
+	}
+	o[OptionUserClass] = v
+	return nil
+}
+
+// option 91, the time since the client last talked to the server,
+// in a reply to a DHCPLEASEQUERY (RFC4388). this is not a lease
+// time, so the largest value is not infinite
+func (o Options) ClientLastTransactionTime() (time.Duration, error) {
+	return o.GetDuration(OptionClientLastTransactionTime, time.Second)
+}
+
+// set option 91, rounding d down to a whole second
+func (o Options) SetClientLastTransactionTime(d time.Duration) error {
+	if d < 0 || d/time.Second > math.MaxUint32 {
+		return &OptionError{OptionClientLastTransactionTime, errors.Errorf("duration %s out of range", d)}
+	}
+	o.setUint32(OptionClientLastTransactionTime, uint32(d/time.Second))
+	return nil
+}
+
+// option 92, the other addresses leased to the client, in a reply
+// to a DHCPLEASEQUERY (RFC4388)
+func (o Options) AssociatedIPs() ([]net.IP, error) {
+	return o.IPs(OptionAssociatedIP)
+}
+
+// set option 92
+func (o Options) SetAssociatedIPs(ips []net.IP) error {
+	return o.SetIPs(OptionAssociatedIP, ips)
+}
+
+// the value of option 114 which means there is no captive portal
+const CaptivePortalUnrestricted = "urn:ietf:params:capport:unrestricted"
+
+// option 114, the URI of the API of a captive portal (RFC8910).
+// CaptivePortalUnrestricted means there is no portal
+func (o Options) CaptivePortal() (string, error) {
+	return o.uri(OptionCaptivePortal)
+}
+
+// set option 114
+func (o Options) SetCaptivePortal(uri string) error {
+	return o.setURI(OptionCaptivePortal, uri)
+}
+
+// option 116, whether a client without an address may configure a
+// link-local one (RFC2563). a client sends it to say it can be told
+// not to, and a server replies false to stop it
+func (o Options) AutoConfigure() (bool, error) {
+	return o.GetBool(OptionAutoConfigure)
+}
+
+// set option 116
+func (o Options) SetAutoConfigure(auto bool) {
+	if auto {
+		o[OptionAutoConfigure] = []byte{1}
+	} else {
+		o[OptionAutoConfigure] = []byte{0}
+	}
+}
+
+// option 118, the subnet a relay or client asks for an address on
+// (RFC3011). if present, it is used instead of giaddr to choose the subnet
+func (o Options) SubnetSelection() (net.IP, error) {
+	return o.ip(OptionSubnetSelection)
+}
+
+// set option 118
+func (o Options) SetSubnetSelection(ip net.IP) error {
+	return o.setIP(OptionSubnetSelection, ip)
+}
+
+// option 119, a list of domain names to search, which may be
+// compressed (RFC3397)
+func (o Options) DomainSearch() ([]string, error) {
+	v, ok := o[OptionDomainSearch]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) == 0 {
+		return nil, &OptionError{OptionDomainSearch, ErrShortRead}
+	}
+
+	var names []string
+	for i := 0; i < len(v); {
+		name, next, err := parseCompressedName(v, i)
+		if err != nil {
+			return nil, &OptionError{OptionDomainSearch, err}
+		}
+		names = append(names, name)
+		i = next
+	}
+	return names, nil
+}
+
+// set option 119, compressing the names
+func (o Options) SetDomainSearch(names []string) error {
+	if len(names) == 0 {
+		return &OptionError{OptionDomainSearch, errors.New("at least one name is needed")}
+	}
+	var v []byte
+	offsets := make(map[string]int)
+	for _, name := range names {
+		var err error
+		if v, err = appendCompressedName(v, name, offsets); err != nil {
+			return &OptionError{OptionDomainSearch, err}
+		}
+	}
+	if len(v) > 255 {
+		return &OptionError{OptionDomainSearch, errors.Errorf("names need %d bytes, more than 255", len(v))}
+	}
+	o[OptionDomainSearch] = v
+	return nil
+}
+
+// the SIP servers of option 120 (RFC3361), given either by Names or
+// by IPs, which are the two encodings of the option
+type SIPServers struct {
+	Names []string
+	IPs   []net.IP
+}
+
+// option 120. compressed names are accepted, with pointers relative
+// to the first name
+func (o Options) SIPServers() (*SIPServers, error) {
+	v, ok := o[OptionSIPServers]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 2 {
+		return nil, &OptionError{OptionSIPServers, ErrShortRead}
+	}
+
+	switch v[0] {
+	case 0:
+		s := &SIPServers{}
+		names := v[1:]
+		for i := 0; i < len(names); {
+			name, next, err := parseCompressedName(names, i)
+			if err != nil {
+				return nil, &OptionError{OptionSIPServers, err}
+			}
+			s.Names = append(s.Names, name)
+			i = next
+		}
+		return s, nil
+	case 1:
+		ips, err := Options{OptionSIPServers: v[1:]}.IPs(OptionSIPServers)
+		if err != nil {
+			return nil, err
+		}
+		return &SIPServers{IPs: ips}, nil
+	}
+	return nil, &OptionError{OptionSIPServers, errors.Errorf("unknown encoding %d", v[0])}
+}
+
+// set option 120, with the encoding for the field of s which is set.
+// names are not compressed
+func (o Options) SetSIPServers(s *SIPServers) error {
+	var v []byte
+	switch {
+	case len(s.Names) > 0 && len(s.IPs) > 0:
+		return &OptionError{OptionSIPServers, errors.New("both names and addresses are set")}
+	case len(s.Names) > 0:
+		v = []byte{0}
+		for _, name := range s.Names {
+			var err error
+			if v, err = appendName(v, name, false); err != nil {
+				return &OptionError{OptionSIPServers, err}
+			}
+		}
+	case len(s.IPs) > 0:
+		tmp := make(Options)
+		if err := tmp.SetIPs(OptionSIPServers, s.IPs); err != nil {
+			return err
+		}
+		v = append([]byte{1}, tmp[OptionSIPServers]...)
+	default:
+		return &OptionError{OptionSIPServers, errors.New("no servers are set")}
+	}
+	if len(v) > 255 {
+		return &OptionError{OptionSIPServers, errors.Errorf("servers need %d bytes, more than 255", len(v))}
+	}
+	o[OptionSIPServers] = v
+	return nil
+}
+
+// whether option 80 is present. it has no value, a client sends it
+// to ask for a DHCPACK straight away in reply to its DHCPDISCOVER,
+// and the server includes it in that DHCPACK (RFC4039)
+func (o Options) RapidCommit() bool {
+	_, ok := o[OptionRapidCommit]
+	return ok
+}
+
+// set option 80
+func (o Options) SetRapidCommit() {
+	o[OptionRapidCommit] = []byte{}
+}
+
+// option 138
+func (o Options) CAPWAPAccessControllers() ([]net.IP, error) {
+	return o.IPs(OptionCAPWAPAccessController)
+}
+
+// set option 138
+func (o Options) SetCAPWAPAccessControllers(ips []net.IP) error {
+	return o.SetIPs(OptionCAPWAPAccessController, ips)
+}
+
+// option 150
+func (o Options) TFTPServers() ([]net.IP, error) {
+	return o.IPs(OptionTFTPServerAddress)
+}
+
+// set option 150
+func (o Options) SetTFTPServers(ips []net.IP) error {
+	return o.SetIPs(OptionTFTPServerAddress, ips)
+}
+
+// option 161, the URL of the MUD file of a device (RFC8520)
+func (o Options) MUDURL() (string, error) {
+	s, err := o.uri(OptionMUDURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(strings.ToLower(s), "https:") {
+		return "", &OptionError{OptionMUDURL, errors.Errorf("url %q is not https", s)}
+	}
+	return s, nil
+}
+
+// set option 161, which must be an https url
+func (o Options) SetMUDURL(url string) error {
+	if !strings.HasPrefix(strings.ToLower(url), "https:") {
+		return &OptionError{OptionMUDURL, errors.Errorf("url %q is not https", url)}
+	}
+	return o.setURI(OptionMUDURL, url)
+}
+
+// option 252, the URL of a proxy auto-config file. this is not in
+// any RFC, but is what browsers using WPAD ask for
+func (o Options) WPAD() (string, error) {
+	return o.GetString(OptionWPAD)
+}
+
+// set option 252
+func (o Options) SetWPAD(url string) error {
+	return o.setStr(OptionWPAD, url)
+}
+
+// get an option which holds an absolute URI
+func (o Options) uri(code OptionCode) (string, error) {
+	s, err := o.GetString(code)
+	if err != nil {
+		return "", err
+	}
+	if err := checkURI(s); err != nil {
+		return "", &OptionError{code, err}
+	}
+	return s, nil
+}
+
+// set an option which holds an absolute URI
+func (o Options) setURI(code OptionCode, s string) error {
+	if err := checkURI(s); err != nil {
+		return &OptionError{code, err}
+	}
+	return o.setStr(code, s)
+}
+
+func checkURI(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() {
+		return errors.Errorf("uri %q is not absolute", s)
+	}
+	return nil
+}
+
+// get an option which holds a 1-byte number
+func (o Options) GetUint8(code OptionCode) (uint8, error) {
+	v, ok := o[code]
+	if !ok {
+		return 0, ErrOptionNotPresent
+	}
+	if len(v) != 1 {
+		return 0, &OptionError{code, errors.Errorf("length %d is not 1", len(v))}
+	}
+	return v[0], nil
+}
+
+// get an option which holds a flag, 0 for false or 1 for true
+// (RFC2132 chapter 2)
+func (o Options) GetBool(code OptionCode) (bool, error) {
+	n, err := o.GetUint8(code)
+	if err != nil {
+		return false, err
+	}
+	if n > 1 {
+		return false, &OptionError{code, errors.Errorf("invalid flag value %d", n)}
+	}
+	return n == 1, nil
+}
+
+// get an option which holds a 4-byte number
+func (o Options) GetUint32(code OptionCode) (uint32, error) {
+	v, ok := o[code]
+	if !ok {
+		return 0, ErrOptionNotPresent
+	}
+	if len(v) != 4 {
+		return 0, &OptionError{code, errors.Errorf("length %d is not 4", len(v))}
+	}
+	return binary.BigEndian.Uint32(v), nil
+}
+
+// get an option which holds a 4-byte number of units, such as
+// time.Second. unlike the accessors of the lease time options, the
+// largest value is not treated as infinite
+func (o Options) GetDuration(code OptionCode, unit time.Duration) (time.Duration, error) {
+	n, err := o.GetUint32(code)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// get an option which holds a 2-byte number
+func (o Options) GetUint16(code OptionCode) (uint16, error) {
+	v, ok := o[code]
+	if !ok {
+		return 0, ErrOptionNotPresent
+	}
+	if len(v) != 2 {
+		return 0, &OptionError{code, errors.Errorf("length %d is not 2", len(v))}
+	}
+	return binary.BigEndian.Uint16(v), nil
+}
+
+// set an option which holds a 2-byte number
+func (o Options) setUint16(code OptionCode, n uint16) {
+	v := make([]byte, 2)
+	binary.BigEndian.PutUint16(v, n)
+	o[code] = v
+}
+
+// set an option which holds a 4-byte number
+func (o Options) setUint32(code OptionCode, n uint32) {
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, n)
+	o[code] = v
+}
+
+// get an option which holds text. some clients terminate it
+// with NULs, which are removed
+func (o Options) GetString(code OptionCode) (string, error) {
+	v, ok := o[code]
+	if !ok {
+		return "", ErrOptionNotPresent
+	}
+	v = bytes.TrimRight(v, "\x00")
+	if len(v) == 0 {
+		return "", &OptionError{code, ErrShortRead}
+	}
+	return string(v), nil
+}
+
+// set an option which holds text, which must fit in one option
+// and must not contain NULs
+func (o Options) setStr(code OptionCode, s string) error {
+	switch {
+	case len(s) == 0:
+		return &OptionError{code, errors.New("empty value")}
+	case len(s) > 255:
+		return &OptionError{code, errors.Errorf("length %d is more than 255", len(s))}
+	case strings.IndexByte(s, 0) >= 0:
+		return &OptionError{code, errors.New("value contains a NUL")}
+	}
+	o[code] = []byte(s)
+	return nil
+}
+
+// get an option which holds a single IPv4 address
+func (o Options) ip(code OptionCode) (net.IP, error) {
+	v, ok := o[code]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) != 4 {
+		return nil, &OptionError{code, errors.Errorf("length %d is not 4", len(v))}
+	}
+	return net.IP(v), nil
+}
+
+// set an option which holds a single IPv4 address
+func (o Options) setIP(code OptionCode, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return &OptionError{code, errors.Wrapf(ErrNotIPv4, "%s", ip)}
+	}
+	o[code] = append([]byte(nil), ip4...)
+	return nil
+}
+
+// get an option which holds a list of IPv4 addresses, such as the
+// many server options without an accessor of their own
+func (o Options) IPs(code OptionCode) ([]net.IP, error) {
+	v, ok := o[code]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 4 {
+		return nil, &OptionError{code, ErrShortRead}
+	}
+	if len(v)%4 != 0 {
+		return nil, &OptionError{code, errors.Errorf("length %d is not a multiple of 4", len(v))}
+	}
+
+	ips := make([]net.IP, 0, len(v)/4)
+	for i := 0; i < len(v); i += 4 {
+		ips = append(ips, net.IP(v[i:i+4]))
+	}
+	return ips, nil
+}
+
+// set an option which holds a list of IPv4 addresses
+func (o Options) SetIPs(code OptionCode, ips []net.IP) error {
+	if len(ips) == 0 {
+		return &OptionError{code, errors.New("at least one address is needed")}
+	}
+	if len(ips) > 63 {
+		return &OptionError{code, errors.Errorf("%d addresses need %d bytes, more than 255", len(ips), 4*len(ips))}
+	}
+	v := make([]byte, 0, 4*len(ips))
+	for _, ip := range ips {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return &OptionError{code, errors.Wrapf(ErrNotIPv4, "%s", ip)}
+		}
+		v = append(v, ip4...)
+	}
+	o[code] = v
+	return nil
+}
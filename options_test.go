@@ -2,7 +2,10 @@ package jdhcp
 
 import (
 	"bytes"
+	"github.com/pkg/errors"
+	"math"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -42,7 +45,7 @@ func TestParseOptions(t *testing.T) {
 		for ek, ev := range tc.asMap {
 			gv, ok := got[ek]
 			if !ok {
-				t.Errorf("case %d key %s missing", i, ek)
+				t.Errorf("case %d key %d missing", i, ek)
 				continue
 			}
 			if bytes.Compare(ev, gv) != 0 {
@@ -54,7 +57,10 @@ func TestParseOptions(t *testing.T) {
 
 func TestOptionsMarshalBytes(t *testing.T) {
 	for i, tc := range optionsParseCases {
-		got := tc.asMap.MarshalBytes()
+		got, err := tc.asMap.MarshalBytes()
+		if err != nil {
+			t.Fatalf("case %d returned error: %s", i, err)
+		}
 
 		if bytes.Compare(tc.asBytes, got) != 0 {
 			t.Errorf("case %d expected %v got %v", i, tc.asBytes, got)
@@ -103,7 +109,7 @@ func TestDHCPMessageType(t *testing.T) {
 	}
 
 	if t1 != t2 {
-		t.Fatalf("returned incorrect type, expected %s got %s", t1, t2)
+		t.Fatalf("returned incorrect type, expected %d got %d", t1, t2)
 	}
 }
 
@@ -130,7 +136,13 @@ func TestParameterRequestList(t *testing.T) {
 func TestRenewalTime(t *testing.T) {
 	o := make(Options)
 	d1 := time.Hour
-	o.Insert(OptionRenewalTime, d1)
+	err := o.SetRenewalTime(d1)
+	if err != nil {
+		t.Fatalf("o.SetRenewalTime() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionRenewalTime], []byte{0x00, 0x00, 0x0e, 0x10}) {
+		t.Fatalf("expected 3600 seconds got % x", o[OptionRenewalTime])
+	}
 
 	d2, err := o.RenewalTime()
 	if err != nil {
@@ -145,7 +157,13 @@ func TestRenewalTime(t *testing.T) {
 func TestRebindingTime(t *testing.T) {
 	o := make(Options)
 	d1 := time.Hour
-	o.Insert(OptionRebindingTime, d1)
+	err := o.SetRebindingTime(d1)
+	if err != nil {
+		t.Fatalf("o.SetRebindingTime() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionRebindingTime], []byte{0x00, 0x00, 0x0e, 0x10}) {
+		t.Fatalf("expected 3600 seconds got % x", o[OptionRebindingTime])
+	}
 
 	d2, err := o.RebindingTime()
 	if err != nil {
@@ -181,3 +199,875 @@ func TestClientID(t *testing.T) {
 		}
 	}
 }
+
+func TestCAPWAPAccessControllers(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)}
+	err := o.SetCAPWAPAccessControllers(l1)
+	if err != nil {
+		t.Fatalf("o.SetCAPWAPAccessControllers() returned error: %s", err)
+	}
+
+	l2, err := o.CAPWAPAccessControllers()
+	if err != nil {
+		t.Fatalf("o.CAPWAPAccessControllers() returned error: %s", err)
+	}
+
+	if len(l1) != len(l2) {
+		t.Fatalf("incorrect length, expected %v got %v", l1, l2)
+	}
+	for i, v := range l1 {
+		if !v.Equal(l2[i]) {
+			t.Fatalf("address list is different, expected %v got %v", l1, l2)
+		}
+	}
+}
+
+func TestTFTPServers(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(10, 0, 0, 5)}
+	err := o.SetTFTPServers(l1)
+	if err != nil {
+		t.Fatalf("o.SetTFTPServers() returned error: %s", err)
+	}
+
+	l2, err := o.TFTPServers()
+	if err != nil {
+		t.Fatalf("o.TFTPServers() returned error: %s", err)
+	}
+
+	if len(l2) != 1 || !l1[0].Equal(l2[0]) {
+		t.Fatalf("address list is different, expected %v got %v", l1, l2)
+	}
+}
+
+func TestIPListInvalid(t *testing.T) {
+	o := make(Options)
+	err := o.SetIPs(OptionCAPWAPAccessController, []net.IP{net.ParseIP("2001:db8::1")})
+	if errors.Cause(err) != ErrNotIPv4 {
+		t.Errorf("expected ErrNotIPv4, got %v", err)
+	}
+	err = o.SetIPs(OptionCAPWAPAccessController, nil)
+	if err == nil {
+		t.Error("expected an error for an empty list")
+	}
+
+	o[OptionCAPWAPAccessController] = []byte{10, 0, 0, 1, 10}
+	_, err = o.IPs(OptionCAPWAPAccessController)
+	if err == nil {
+		t.Error("expected an error for a bad length")
+	}
+}
+
+func TestParseOptionsPartial(t *testing.T) {
+	for i, tc := range optionsParseCases {
+		got, problems := ParseOptionsPartial(tc.asBytes)
+		if len(problems) != 0 {
+			t.Errorf("case %d returned problems: %v", i, problems)
+			continue
+		}
+		if len(tc.asMap) != len(got) {
+			t.Errorf("case %d returned incorrect length, expected %d got %d",
+				i, len(tc.asMap), len(got))
+		}
+	}
+
+	// truncated value and missing end option
+	got, problems := ParseOptionsPartial([]byte{0x35, 0x01, 0x01, 0x32, 0x04, 0xC0, 0xA8})
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if bytes.Compare(got[OptionRequestedIPAddress], []byte{0xC0, 0xA8}) != 0 {
+		t.Errorf("expected truncated value, got %v", got[OptionRequestedIPAddress])
+	}
+}
+
+func TestOptionsMarshalBytesOrdered(t *testing.T) {
+	o := Options{
+		OptionSubnetMask:       {0xff, 0xff, 0xff, 0x00},
+		OptionDHCPMessageType:  {0x02},
+		OptionServerIdentifier: {0xc0, 0xa8, 0x01, 0x01},
+		OptionRenewalTime:      {0x00, 0x00, 0x0e, 0x10},
+	}
+	order := RequestedOrder([]OptionCode{OptionRenewalTime, OptionSubnetMask})
+
+	expected := []byte{
+		0x35, 0x01, 0x02,
+		0x36, 0x04, 0xc0, 0xa8, 0x01, 0x01,
+		0x3a, 0x04, 0x00, 0x00, 0x0e, 0x10,
+		0x01, 0x04, 0xff, 0xff, 0xff, 0x00,
+		0xff,
+	}
+	got, err := o.MarshalBytesOrdered(order)
+	if err != nil {
+		t.Fatalf("o.MarshalBytesOrdered() returned error: %s", err)
+	}
+	if bytes.Compare(expected, got) != 0 {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+
+	// options not in the order are sorted numerically afterwards
+	expected = []byte{
+		0x3a, 0x04, 0x00, 0x00, 0x0e, 0x10,
+		0x01, 0x04, 0xff, 0xff, 0xff, 0x00,
+		0x35, 0x01, 0x02,
+		0x36, 0x04, 0xc0, 0xa8, 0x01, 0x01,
+		0xff,
+	}
+	got, _ = o.MarshalBytesOrdered([]OptionCode{OptionRenewalTime, OptionRenewalTime})
+	if bytes.Compare(expected, got) != 0 {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+}
+
+func TestInfiniteLease(t *testing.T) {
+	o := make(Options)
+	err := o.SetIPAddressLeaseTime(InfiniteLease)
+	if err != nil {
+		t.Fatalf("o.SetIPAddressLeaseTime() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionIPAddressLeaseTime], []byte{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("expected ff ff ff ff got % x", o[OptionIPAddressLeaseTime])
+	}
+
+	d, err := o.IPAddressLeaseTime()
+	if err != nil {
+		t.Fatalf("o.IPAddressLeaseTime() returned error: %s", err)
+	}
+	if d != InfiniteLease {
+		t.Errorf("expected InfiniteLease got %v", d)
+	}
+
+	for _, d := range []time.Duration{-time.Second, infiniteSeconds * time.Second} {
+		if err := o.SetIPAddressLeaseTime(d); err == nil {
+			t.Errorf("expected error setting lease time %s", d)
+		}
+	}
+
+	o[OptionIPAddressLeaseTime] = []byte{0, 0, 1}
+	var oe *OptionError
+	if _, err := o.IPAddressLeaseTime(); !errors.As(err, &oe) {
+		t.Errorf("expected OptionError for a short lease time got %v", err)
+	}
+}
+
+func TestRouters(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 254)}
+	err := o.SetRouters(l1)
+	if err != nil {
+		t.Fatalf("o.SetRouters() returned error: %s", err)
+	}
+
+	l2, err := o.Routers()
+	if err != nil {
+		t.Fatalf("o.Routers() returned error: %s", err)
+	}
+
+	if len(l2) != 2 || !l1[0].Equal(l2[0]) || !l1[1].Equal(l2[1]) {
+		t.Fatalf("address list is different, expected %v got %v", l1, l2)
+	}
+
+	o[OptionRouter] = []byte{10, 0, 0, 1, 10}
+	if _, err := o.Routers(); err == nil {
+		t.Errorf("expected error for a length which is not a multiple of 4")
+	}
+}
+
+func TestDNSServers(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(192, 0, 2, 53), net.IPv4(192, 0, 2, 54)}
+	err := o.SetDNSServers(l1)
+	if err != nil {
+		t.Fatalf("o.SetDNSServers() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionDomainNameServer], []byte{192, 0, 2, 53, 192, 0, 2, 54}) {
+		t.Fatalf("unexpected value % x", o[OptionDomainNameServer])
+	}
+
+	l2, err := o.DNSServers()
+	if err != nil {
+		t.Fatalf("o.DNSServers() returned error: %s", err)
+	}
+
+	if len(l2) != 2 || !l1[0].Equal(l2[0]) || !l1[1].Equal(l2[1]) {
+		t.Fatalf("address list is different, expected %v got %v", l1, l2)
+	}
+}
+
+func TestServerID(t *testing.T) {
+	o := make(Options)
+	a1 := net.IPv4(192, 0, 2, 1)
+	err := o.SetServerID(a1)
+	if err != nil {
+		t.Fatalf("o.SetServerID() returned error: %s", err)
+	}
+	if len(o[OptionServerIdentifier]) != 4 {
+		t.Fatalf("expected 4 byte value got % x", o[OptionServerIdentifier])
+	}
+
+	a2, err := o.ServerID()
+	if err != nil {
+		t.Fatalf("o.ServerID() returned error: %s", err)
+	}
+	if !a1.Equal(a2) {
+		t.Fatalf("address is different, expected %v got %v", a1, a2)
+	}
+
+	if err := o.SetServerID(net.ParseIP("2001:db8::1")); err == nil {
+		t.Errorf("expected error for an IPv6 address")
+	}
+	o[OptionServerIdentifier] = []byte{192, 0, 2}
+	if _, err := o.ServerID(); err == nil {
+		t.Errorf("expected error for a 3 byte value")
+	}
+}
+
+func TestHostName(t *testing.T) {
+	o := make(Options)
+	err := o.SetHostName("printer")
+	if err != nil {
+		t.Fatalf("o.SetHostName() returned error: %s", err)
+	}
+
+	name, err := o.HostName()
+	if err != nil {
+		t.Fatalf("o.HostName() returned error: %s", err)
+	}
+	if name != "printer" {
+		t.Fatalf("host name is different, expected printer got %q", name)
+	}
+
+	o[OptionHostName] = []byte("printer\x00")
+	if name, _ := o.HostName(); name != "printer" {
+		t.Errorf("expected trailing NUL to be removed, got %q", name)
+	}
+
+	for _, bad := range []string{"", "a\x00b", strings.Repeat("a", 256)} {
+		if err := o.SetHostName(bad); err == nil {
+			t.Errorf("expected error for host name %q", bad)
+		}
+	}
+}
+
+func TestDomainName(t *testing.T) {
+	o := make(Options)
+	err := o.SetDomainName("example.com")
+	if err != nil {
+		t.Fatalf("o.SetDomainName() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionDomainName], []byte("example.com")) {
+		t.Fatalf("unexpected value %q", o[OptionDomainName])
+	}
+
+	// as sent by dnsmasq and others
+	o[OptionDomainName] = []byte("example.com\x00\x00")
+	name, err := o.DomainName()
+	if err != nil {
+		t.Fatalf("o.DomainName() returned error: %s", err)
+	}
+	if name != "example.com" {
+		t.Fatalf("domain name is different, expected example.com got %q", name)
+	}
+
+	o[OptionDomainName] = []byte{0}
+	if _, err := o.DomainName(); err == nil {
+		t.Errorf("expected error for a value of only NULs")
+	}
+}
+
+func TestBroadcastAddress(t *testing.T) {
+	o := make(Options)
+	a1 := net.IPv4(192, 0, 2, 255)
+	err := o.SetBroadcastAddress(a1)
+	if err != nil {
+		t.Fatalf("o.SetBroadcastAddress() returned error: %s", err)
+	}
+
+	a2, err := o.BroadcastAddress()
+	if err != nil {
+		t.Fatalf("o.BroadcastAddress() returned error: %s", err)
+	}
+	if !a1.Equal(a2) {
+		t.Fatalf("address is different, expected %v got %v", a1, a2)
+	}
+
+	o[OptionBroadcastAddress] = []byte{192, 0, 2, 255, 0}
+	if _, err := o.BroadcastAddress(); err == nil {
+		t.Errorf("expected error for a 5 byte value")
+	}
+}
+
+func TestInterfaceMTU(t *testing.T) {
+	o := make(Options)
+	err := o.SetInterfaceMTU(9000)
+	if err != nil {
+		t.Fatalf("o.SetInterfaceMTU() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionInterfaceMTU], []byte{0x23, 0x28}) {
+		t.Fatalf("unexpected value % x", o[OptionInterfaceMTU])
+	}
+
+	mtu, err := o.InterfaceMTU()
+	if err != nil {
+		t.Fatalf("o.InterfaceMTU() returned error: %s", err)
+	}
+	if mtu != 9000 {
+		t.Fatalf("mtu is different, expected 9000 got %d", mtu)
+	}
+
+	if err := o.SetInterfaceMTU(67); err == nil {
+		t.Errorf("expected error for an mtu of 67")
+	}
+	o[OptionInterfaceMTU] = []byte{0x05, 0xdc, 0}
+	if _, err := o.InterfaceMTU(); err == nil {
+		t.Errorf("expected error for a 3 byte value")
+	}
+}
+
+func TestNTPServers(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(192, 0, 2, 123)}
+	err := o.SetNTPServers(l1)
+	if err != nil {
+		t.Fatalf("o.SetNTPServers() returned error: %s", err)
+	}
+
+	l2, err := o.NTPServers()
+	if err != nil {
+		t.Fatalf("o.NTPServers() returned error: %s", err)
+	}
+
+	if len(l2) != 1 || !l1[0].Equal(l2[0]) {
+		t.Fatalf("address list is different, expected %v got %v", l1, l2)
+	}
+}
+
+func TestNetBIOS(t *testing.T) {
+	o := make(Options)
+	l1 := []net.IP{net.IPv4(192, 0, 2, 139)}
+	if err := o.SetNetBIOSNameServers(l1); err != nil {
+		t.Fatalf("o.SetNetBIOSNameServers() returned error: %s", err)
+	}
+	if err := o.SetNetBIOSNodeType(NetBIOSHNode); err != nil {
+		t.Fatalf("o.SetNetBIOSNodeType() returned error: %s", err)
+	}
+	if err := o.SetNetBIOSScope("corp"); err != nil {
+		t.Fatalf("o.SetNetBIOSScope() returned error: %s", err)
+	}
+
+	l2, err := o.NetBIOSNameServers()
+	if err != nil || len(l2) != 1 || !l1[0].Equal(l2[0]) {
+		t.Errorf("expected name servers %v got %v (%v)", l1, l2, err)
+	}
+	nt, err := o.NetBIOSNodeType()
+	if err != nil || nt != NetBIOSHNode {
+		t.Errorf("expected node type %d got %d (%v)", NetBIOSHNode, nt, err)
+	}
+	scope, err := o.NetBIOSScope()
+	if err != nil || scope != "corp" {
+		t.Errorf("expected scope corp got %q (%v)", scope, err)
+	}
+
+	if err := o.SetNetBIOSNodeType(3); err == nil {
+		t.Errorf("expected error for node type 3")
+	}
+	o[OptionNetBIOSNodeType] = []byte{8, 8}
+	if _, err := o.NetBIOSNodeType(); err == nil {
+		t.Errorf("expected error for a 2 byte value")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	o := make(Options)
+	err := o.SetMaxMessageSize(1500)
+	if err != nil {
+		t.Fatalf("o.SetMaxMessageSize() returned error: %s", err)
+	}
+
+	size, err := o.MaxMessageSize()
+	if err != nil {
+		t.Fatalf("o.MaxMessageSize() returned error: %s", err)
+	}
+	if size != 1500 {
+		t.Fatalf("size is different, expected 1500 got %d", size)
+	}
+
+	if err := o.SetMaxMessageSize(575); err == nil {
+		t.Errorf("expected error for a size of 575")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	o := make(Options)
+	err := o.SetMessage("address not on this network")
+	if err != nil {
+		t.Fatalf("o.SetMessage() returned error: %s", err)
+	}
+
+	msg, err := o.Message()
+	if err != nil {
+		t.Fatalf("o.Message() returned error: %s", err)
+	}
+	if msg != "address not on this network" {
+		t.Fatalf("message is different, got %q", msg)
+	}
+}
+
+func TestVendorClassIdentifier(t *testing.T) {
+	o := make(Options)
+	err := o.SetVendorClassIdentifier("MSFT 5.0")
+	if err != nil {
+		t.Fatalf("o.SetVendorClassIdentifier() returned error: %s", err)
+	}
+
+	class, err := o.VendorClassIdentifier()
+	if err != nil {
+		t.Fatalf("o.VendorClassIdentifier() returned error: %s", err)
+	}
+	if class != "MSFT 5.0" {
+		t.Fatalf("vendor class is different, expected MSFT 5.0 got %q", class)
+	}
+
+	delete(o, OptionVendorClassIdentifier)
+	if _, err := o.VendorClassIdentifier(); err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent got %v", err)
+	}
+}
+
+func TestUserClass(t *testing.T) {
+	o := make(Options)
+	err := o.SetUserClass([][]byte{[]byte("ipxe"), []byte("lab")})
+	if err != nil {
+		t.Fatalf("o.SetUserClass() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionUserClass], []byte("\x04ipxe\x03lab")) {
+		t.Fatalf("unexpected value %q", o[OptionUserClass])
+	}
+
+	classes, err := o.UserClass()
+	if err != nil {
+		t.Fatalf("o.UserClass() returned error: %s", err)
+	}
+	if len(classes) != 2 || string(classes[0]) != "ipxe" || string(classes[1]) != "lab" {
+		t.Fatalf("unexpected classes %q", classes)
+	}
+
+	// the legacy form without a length
+	o[OptionUserClass] = []byte("RRAS.Microsoft")
+	classes, err = o.UserClass()
+	if err != nil {
+		t.Fatalf("o.UserClass() returned error: %s", err)
+	}
+	if len(classes) != 1 || string(classes[0]) != "RRAS.Microsoft" {
+		t.Fatalf("unexpected legacy classes %q", classes)
+	}
+
+	if err := o.SetUserClass([][]byte{{}}); err == nil {
+		t.Errorf("expected error for an empty class")
+	}
+}
+
+func TestDomainSearch(t *testing.T) {
+	o := make(Options)
+	err := o.SetDomainSearch([]string{"eng.apple.com", "marketing.apple.com."})
+	if err != nil {
+		t.Fatalf("o.SetDomainSearch() returned error: %s", err)
+	}
+	// the example of RFC3397 chapter 3
+	want := []byte("\x03eng\x05apple\x03com\x00\x09marketing\xc0\x04")
+	if !bytes.Equal(o[OptionDomainSearch], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionDomainSearch])
+	}
+
+	names, err := o.DomainSearch()
+	if err != nil {
+		t.Fatalf("o.DomainSearch() returned error: %s", err)
+	}
+	if len(names) != 2 || names[0] != "eng.apple.com" || names[1] != "marketing.apple.com" {
+		t.Fatalf("unexpected names %q", names)
+	}
+
+	for i, v := range []string{
+		"\x03eng\x05apple",           // no root label
+		"\x03eng\xc0\x00",            // pointer loop
+		"\x03eng\x00\x03www\xc0\x0a", // forward pointer
+		"\x03eng\x00\x03www\xc0",     // truncated pointer
+	} {
+		o[OptionDomainSearch] = []byte(v)
+		if _, err := o.DomainSearch(); err == nil {
+			t.Errorf("%d: expected error for %q", i, v)
+		}
+	}
+}
+
+func TestSIPServers(t *testing.T) {
+	o := make(Options)
+	err := o.SetSIPServers(&SIPServers{Names: []string{"example.com", "sip.example.org"}})
+	if err != nil {
+		t.Fatalf("o.SetSIPServers() returned error: %s", err)
+	}
+	want := []byte("\x00\x07example\x03com\x00\x03sip\x07example\x03org\x00")
+	if !bytes.Equal(o[OptionSIPServers], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionSIPServers])
+	}
+	s, err := o.SIPServers()
+	if err != nil {
+		t.Fatalf("o.SIPServers() returned error: %s", err)
+	}
+	if len(s.Names) != 2 || s.Names[0] != "example.com" || s.Names[1] != "sip.example.org" || s.IPs != nil {
+		t.Fatalf("unexpected servers %+v", s)
+	}
+
+	// compressed names, as in the example of RFC3361 chapter 3.1
+	o[OptionSIPServers] = []byte("\x00\x07example\x03com\x00\x06sipsrv\xc0\x00")
+	s, err = o.SIPServers()
+	if err != nil {
+		t.Fatalf("o.SIPServers() returned error: %s", err)
+	}
+	if len(s.Names) != 2 || s.Names[1] != "sipsrv.example.com" {
+		t.Fatalf("unexpected compressed servers %+v", s)
+	}
+
+	err = o.SetSIPServers(&SIPServers{IPs: []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2)}})
+	if err != nil {
+		t.Fatalf("o.SetSIPServers() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionSIPServers], []byte{1, 192, 0, 2, 1, 192, 0, 2, 2}) {
+		t.Fatalf("unexpected value % x", o[OptionSIPServers])
+	}
+	s, err = o.SIPServers()
+	if err != nil {
+		t.Fatalf("o.SIPServers() returned error: %s", err)
+	}
+	if len(s.IPs) != 2 || !s.IPs[1].Equal(net.IPv4(192, 0, 2, 2)) || s.Names != nil {
+		t.Fatalf("unexpected servers %+v", s)
+	}
+
+	o[OptionSIPServers] = []byte{2, 1, 2, 3, 4}
+	if _, err := o.SIPServers(); err == nil {
+		t.Errorf("expected error for an unknown encoding")
+	}
+	if err := o.SetSIPServers(&SIPServers{}); err == nil {
+		t.Errorf("expected error for no servers")
+	}
+}
+
+func TestRapidCommit(t *testing.T) {
+	o := Options{OptionDHCPMessageType: {byte(Discover)}}
+	if o.RapidCommit() {
+		t.Fatalf("expected no rapid commit")
+	}
+	o.SetRapidCommit()
+
+	b, err := o.MarshalBytes()
+	if err != nil {
+		t.Fatalf("o.MarshalBytes() returned error: %s", err)
+	}
+	if !bytes.Equal(b, []byte{53, 1, 1, 80, 0, 255}) {
+		t.Fatalf("unexpected encoding % x", b)
+	}
+	for i, data := range [][]byte{b, b[:len(b)-1]} {
+		o2, err := ParseOptions(data)
+		if err != nil {
+			t.Fatalf("%d: ParseOptions() returned error: %s", i, err)
+		}
+		if !o2.RapidCommit() || len(o2[OptionRapidCommit]) != 0 {
+			t.Errorf("%d: expected rapid commit to be parsed", i)
+		}
+	}
+	o2, _ := ParseOptionsPartial(b)
+	if !o2.RapidCommit() {
+		t.Errorf("expected rapid commit to be parsed by ParseOptionsPartial")
+	}
+}
+
+func TestSubnetSelection(t *testing.T) {
+	o := make(Options)
+	s1 := net.IPv4(10, 1, 0, 0)
+	err := o.SetSubnetSelection(s1)
+	if err != nil {
+		t.Fatalf("o.SetSubnetSelection() returned error: %s", err)
+	}
+
+	s2, err := o.SubnetSelection()
+	if err != nil {
+		t.Fatalf("o.SubnetSelection() returned error: %s", err)
+	}
+	if !s1.Equal(s2) {
+		t.Fatalf("address is different, expected %v got %v", s1, s2)
+	}
+
+	if err := o.SetSubnetSelection(net.ParseIP("2001:db8::")); err == nil {
+		t.Errorf("expected error for an IPv6 address")
+	}
+}
+
+func TestTimeOffset(t *testing.T) {
+	o := make(Options)
+	for _, d := range []time.Duration{-5 * time.Hour, 0, 5*time.Hour + 30*time.Minute} {
+		err := o.SetTimeOffset(d)
+		if err != nil {
+			t.Fatalf("o.SetTimeOffset(%s) returned error: %s", d, err)
+		}
+		got, err := o.TimeOffset()
+		if err != nil {
+			t.Fatalf("o.TimeOffset() returned error: %s", err)
+		}
+		if got != d {
+			t.Errorf("expected %s got %s", d, got)
+		}
+	}
+	if !bytes.Equal(o[OptionTimeOffset], []byte{0x00, 0x00, 0x4d, 0x58}) {
+		t.Errorf("unexpected value % x", o[OptionTimeOffset])
+	}
+
+	o.SetTimeOffset(-time.Hour)
+	if !bytes.Equal(o[OptionTimeOffset], []byte{0xff, 0xff, 0xf1, 0xf0}) {
+		t.Errorf("unexpected value for a negative offset % x", o[OptionTimeOffset])
+	}
+	if err := o.SetTimeOffset(100 * 365 * 24 * time.Hour); err == nil {
+		t.Errorf("expected error for an offset out of range")
+	}
+}
+
+func TestRootPath(t *testing.T) {
+	o := make(Options)
+	err := o.SetRootPath("/srv/nfs/root")
+	if err != nil {
+		t.Fatalf("o.SetRootPath() returned error: %s", err)
+	}
+	p, err := o.RootPath()
+	if err != nil {
+		t.Fatalf("o.RootPath() returned error: %s", err)
+	}
+	if p != "/srv/nfs/root" {
+		t.Errorf("unexpected root path %q", p)
+	}
+}
+
+func TestWPAD(t *testing.T) {
+	o := make(Options)
+	err := o.SetWPAD("http://wpad.example.com/wpad.dat")
+	if err != nil {
+		t.Fatalf("o.SetWPAD() returned error: %s", err)
+	}
+
+	// some clients expect the value to end with a nul
+	o[OptionWPAD] = append(o[OptionWPAD], 0)
+	url, err := o.WPAD()
+	if err != nil {
+		t.Fatalf("o.WPAD() returned error: %s", err)
+	}
+	if url != "http://wpad.example.com/wpad.dat" {
+		t.Errorf("unexpected url %q", url)
+	}
+}
+
+func TestCaptivePortal(t *testing.T) {
+	o := make(Options)
+	for _, uri := range []string{"https://portal.example.com/api", CaptivePortalUnrestricted} {
+		err := o.SetCaptivePortal(uri)
+		if err != nil {
+			t.Fatalf("o.SetCaptivePortal(%q) returned error: %s", uri, err)
+		}
+		got, err := o.CaptivePortal()
+		if err != nil {
+			t.Fatalf("o.CaptivePortal() returned error: %s", err)
+		}
+		if got != uri {
+			t.Errorf("expected %q got %q", uri, got)
+		}
+	}
+
+	if err := o.SetCaptivePortal("/api"); err == nil {
+		t.Errorf("expected error for a relative uri")
+	}
+	o[OptionCaptivePortal] = []byte("portal.example.com")
+	if _, err := o.CaptivePortal(); err == nil {
+		t.Errorf("expected error for a uri without a scheme")
+	}
+}
+
+func TestMUDURL(t *testing.T) {
+	o := make(Options)
+	err := o.SetMUDURL("https://things.example.com/lightbulb2000")
+	if err != nil {
+		t.Fatalf("o.SetMUDURL() returned error: %s", err)
+	}
+	url, err := o.MUDURL()
+	if err != nil {
+		t.Fatalf("o.MUDURL() returned error: %s", err)
+	}
+	if url != "https://things.example.com/lightbulb2000" {
+		t.Errorf("unexpected url %q", url)
+	}
+
+	if err := o.SetMUDURL("http://things.example.com/lightbulb2000"); err == nil {
+		t.Errorf("expected error for an http url")
+	}
+	o[OptionMUDURL] = []byte("ftp://things.example.com/lightbulb2000")
+	if _, err := o.MUDURL(); err == nil {
+		t.Errorf("expected error for an ftp url")
+	}
+}
+
+func TestLeaseQueryOptions(t *testing.T) {
+	o := make(Options)
+	err := o.SetClientLastTransactionTime(90 * time.Second)
+	if err != nil {
+		t.Fatalf("o.SetClientLastTransactionTime() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionClientLastTransactionTime], []byte{0, 0, 0, 90}) {
+		t.Fatalf("unexpected value % x", o[OptionClientLastTransactionTime])
+	}
+	d, err := o.ClientLastTransactionTime()
+	if err != nil {
+		t.Fatalf("o.ClientLastTransactionTime() returned error: %s", err)
+	}
+	if d != 90*time.Second {
+		t.Errorf("expected 1m30s got %s", d)
+	}
+
+	// the largest value is a time like any other, not an infinite lease
+	o[OptionClientLastTransactionTime] = []byte{0xff, 0xff, 0xff, 0xff}
+	d, err = o.ClientLastTransactionTime()
+	if err != nil || d != math.MaxUint32*time.Second {
+		t.Errorf("expected %d seconds got %s, %v", uint32(math.MaxUint32), d, err)
+	}
+	if err := o.SetClientLastTransactionTime(InfiniteLease); err == nil {
+		t.Errorf("expected error for InfiniteLease")
+	}
+
+	ips1 := []net.IP{net.IPv4(10, 0, 0, 7), net.IPv4(10, 0, 0, 8)}
+	err = o.SetAssociatedIPs(ips1)
+	if err != nil {
+		t.Fatalf("o.SetAssociatedIPs() returned error: %s", err)
+	}
+	ips2, err := o.AssociatedIPs()
+	if err != nil {
+		t.Fatalf("o.AssociatedIPs() returned error: %s", err)
+	}
+	if len(ips2) != 2 || !ips2[0].Equal(ips1[0]) || !ips2[1].Equal(ips1[1]) {
+		t.Errorf("expected %v got %v", ips1, ips2)
+	}
+}
+
+func TestAutoConfigure(t *testing.T) {
+	o := make(Options)
+	for _, auto := range []bool{true, false} {
+		o.SetAutoConfigure(auto)
+		got, err := o.AutoConfigure()
+		if err != nil {
+			t.Fatalf("o.AutoConfigure() returned error: %s", err)
+		}
+		if got != auto {
+			t.Errorf("expected %v got %v", auto, got)
+		}
+	}
+	if !bytes.Equal(o[OptionAutoConfigure], []byte{0}) {
+		t.Errorf("unexpected value % x", o[OptionAutoConfigure])
+	}
+
+	o[OptionAutoConfigure] = []byte{2}
+	if _, err := o.AutoConfigure(); err == nil {
+		t.Errorf("expected error for an invalid value")
+	}
+}
+
+func TestIPs(t *testing.T) {
+	o := make(Options)
+	lpr := OptionCode(9)
+	ips1 := []net.IP{net.IPv4(192, 0, 2, 10), net.IPv4(192, 0, 2, 11)}
+	err := o.SetIPs(lpr, ips1)
+	if err != nil {
+		t.Fatalf("o.SetIPs() returned error: %s", err)
+	}
+
+	ips2, err := o.IPs(lpr)
+	if err != nil {
+		t.Fatalf("o.IPs() returned error: %s", err)
+	}
+	if len(ips2) != 2 || !ips2[0].Equal(ips1[0]) || !ips2[1].Equal(ips1[1]) {
+		t.Errorf("expected %v got %v", ips1, ips2)
+	}
+
+	if _, err := o.IPs(OptionCode(7)); err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent, got %v", err)
+	}
+	if err := o.SetIPs(lpr, nil); err == nil {
+		t.Errorf("expected error for no addresses")
+	}
+}
+
+func TestSetIPsTooMany(t *testing.T) {
+	o := make(Options)
+	ips := make([]net.IP, 64)
+	for i := range ips {
+		ips[i] = net.IPv4(192, 0, 2, byte(i))
+	}
+	if err := o.SetRouters(ips); err == nil {
+		t.Fatalf("expected error for 64 addresses")
+	}
+	if _, ok := o[OptionRouter]; ok {
+		t.Errorf("option set despite error")
+	}
+	if err := o.SetRouters(ips[:63]); err != nil {
+		t.Fatalf("o.SetRouters() returned error for 63 addresses: %s", err)
+	}
+	if len(o[OptionRouter]) != 252 {
+		t.Errorf("expected 252 bytes, got %d", len(o[OptionRouter]))
+	}
+}
+
+func TestOptionsMarshalBytesTooLong(t *testing.T) {
+	o := Options{OptionRouter: make([]byte, 256)}
+	_, err := o.MarshalBytes()
+	if oe, ok := err.(*OptionError); !ok || oe.Code != OptionRouter {
+		t.Fatalf("expected OptionError for option 3, got %v", err)
+	}
+}
+
+func TestScalarGetters(t *testing.T) {
+	o := Options{
+		OptionCode(19): {1},                      // ip forwarding
+		OptionCode(23): {64},                     // default ttl
+		OptionCode(22): {0x02, 0x40},             // max datagram reassembly size
+		OptionCode(24): {0x00, 0x00, 0x02, 0x58}, // path mtu aging timeout
+		OptionCode(40): []byte("nis\x00"),        // nis domain
+	}
+
+	if b, err := o.GetBool(OptionCode(19)); err != nil || !b {
+		t.Errorf("expected true, got %v, %v", b, err)
+	}
+	if n, err := o.GetUint8(OptionCode(23)); err != nil || n != 64 {
+		t.Errorf("expected 64, got %d, %v", n, err)
+	}
+	if n, err := o.GetUint16(OptionCode(22)); err != nil || n != 576 {
+		t.Errorf("expected 576, got %d, %v", n, err)
+	}
+	if n, err := o.GetUint32(OptionCode(24)); err != nil || n != 600 {
+		t.Errorf("expected 600, got %d, %v", n, err)
+	}
+	if d, err := o.GetDuration(OptionCode(24), time.Second); err != nil || d != 10*time.Minute {
+		t.Errorf("expected 10m, got %s, %v", d, err)
+	}
+	if s, err := o.GetString(OptionCode(40)); err != nil || s != "nis" {
+		t.Errorf("expected nis, got %q, %v", s, err)
+	}
+
+	// lengths are checked
+	if _, err := o.GetUint32(OptionCode(22)); err == nil {
+		t.Errorf("expected error for a 2 byte value")
+	}
+	if _, err := o.GetUint8(OptionCode(22)); err == nil {
+		t.Errorf("expected error for a 2 byte value")
+	}
+	o[OptionCode(19)] = []byte{2}
+	if _, err := o.GetBool(OptionCode(19)); err == nil {
+		t.Errorf("expected error for an invalid flag")
+	}
+	if _, err := o.GetUint16(OptionCode(99)); err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package jdhcp
+
+import (
+	"context"
+	"net"
+)
+
+// the interface and local address a message was received on, which
+// are only known when the socket is bound to the wildcard address on
+// a platform that reports them (IP_PKTINFO)
+type packetInfo struct {
+	ifindex int
+	local   net.IP
+}
+
+// choose the source address of the response res on the interface
+// that its request arrived on. clients check that the reply comes
+// from the server identifier (option 54), so that is used if the
+// handler set it, and otherwise the address of the interface
+func (p *packetInfo) source(res *Msg) *packetInfo {
+	src := *p
+	if id, err := res.ServerID(); err == nil {
+		src.local = id
+	}
+	return &src
+}
+
+type ifindexKey struct{}
+
+// get the index of the interface that the message being handled
+// arrived on, or 0 if it is not known (see packetInfo)
+func InterfaceIndexFromContext(ctx context.Context) int {
+	i, _ := ctx.Value(ifindexKey{}).(int)
+	return i
+}
@@ -0,0 +1,73 @@
+package jdhcp
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// ask for the interface and local address of each message received on
+// conn, if it is a UDP socket bound to the wildcard address. without
+// them the kernel picks the source address of replies itself
+func enablePacketInfo(conn net.PacketConn) bool {
+	c, ok := conn.(*net.UDPConn)
+	if !ok {
+		return false
+	}
+	if addr, ok := c.LocalAddr().(*net.UDPAddr); !ok || !addr.IP.IsUnspecified() {
+		return false
+	}
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_PKTINFO, 1)
+	})
+	return err == nil && serr == nil
+}
+
+// read a message from conn along with its packetInfo, which is nil if
+// the kernel did not include it
+func readWithInfo(conn net.PacketConn, b []byte) (int, net.Addr, *packetInfo, error) {
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofInet4Pktinfo))
+	n, oobn, _, from, err := conn.(*net.UDPConn).ReadMsgUDP(b, oob)
+	if err != nil {
+		return n, nil, nil, err
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return n, from, nil, nil
+	}
+	for _, m := range msgs {
+		if m.Header.Level != syscall.IPPROTO_IP || m.Header.Type != syscall.IP_PKTINFO ||
+			len(m.Data) < syscall.SizeofInet4Pktinfo {
+			continue
+		}
+		pi := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&m.Data[0]))
+		return n, from, &packetInfo{
+			ifindex: int(pi.Ifindex),
+			local:   net.IPv4(pi.Spec_dst[0], pi.Spec_dst[1], pi.Spec_dst[2], pi.Spec_dst[3]),
+		}, nil
+	}
+	return n, from, nil, nil
+}
+
+// send b to addr over conn, from the interface and local address in info
+func writeWithInfo(conn net.PacketConn, b []byte, addr *net.UDPAddr, info *packetInfo) (int, error) {
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofInet4Pktinfo))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = syscall.IPPROTO_IP
+	h.Type = syscall.IP_PKTINFO
+	h.SetLen(syscall.CmsgLen(syscall.SizeofInet4Pktinfo))
+
+	pi := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&oob[syscall.CmsgLen(0)]))
+	pi.Ifindex = int32(info.ifindex)
+	copy(pi.Spec_dst[:], info.local.To4())
+
+	n, _, err := conn.(*net.UDPConn).WriteMsgUDP(b, oob, addr)
+	return n, err
+}
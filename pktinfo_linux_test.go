@@ -0,0 +1,76 @@
+package jdhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPacketInfo(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer conn.Close()
+	if !enablePacketInfo(conn) {
+		t.Fatalf("cannot enable IP_PKTINFO")
+	}
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(time.Second))
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, err = client.WriteTo([]byte("ping"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("cannot send: %s", err)
+	}
+	buf := make([]byte, 16)
+	n, from, info, err := readWithInfo(conn, buf)
+	if err != nil {
+		t.Fatalf("cannot read: %s", err)
+	}
+	if string(buf[:n]) != "ping" {
+		t.Errorf("expected ping got %q", buf[:n])
+	}
+	if info == nil || !info.local.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("expected local address 127.0.0.1 got %+v", info)
+	}
+
+	// reply from another loopback address, standing in for a
+	// server identifier which differs from the interface address
+	res := NewMsg()
+	res.Options[OptionServerIdentifier] = []byte{127, 0, 0, 2}
+	_, err = writeWithInfo(conn, []byte("pong"), from.(*net.UDPAddr), info.source(res))
+	if err != nil {
+		t.Fatalf("cannot send reply: %s", err)
+	}
+
+	n, src, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("cannot read reply: %s", err)
+	}
+	if string(buf[:n]) != "pong" {
+		t.Errorf("expected pong got %q", buf[:n])
+	}
+	if ip := src.(*net.UDPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 2)) {
+		t.Errorf("expected reply from 127.0.0.2 got %s", ip)
+	}
+}
+
+func TestPacketInfoBoundAddress(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer conn.Close()
+
+	// the kernel already uses the bound address as the source
+	if enablePacketInfo(conn) {
+		t.Errorf("expected IP_PKTINFO to be left off for a bound address")
+	}
+}
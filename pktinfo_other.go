@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package jdhcp
+
+import (
+	"net"
+)
+
+// the source address of replies is left to the kernel
+func enablePacketInfo(conn net.PacketConn) bool {
+	return false
+}
+
+func readWithInfo(conn net.PacketConn, b []byte) (int, net.Addr, *packetInfo, error) {
+	n, from, err := conn.ReadFrom(b)
+	return n, from, nil, err
+}
+
+func writeWithInfo(conn net.PacketConn, b []byte, addr *net.UDPAddr, info *packetInfo) (int, error) {
+	return conn.WriteTo(b, addr)
+}
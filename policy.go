@@ -0,0 +1,124 @@
+package jdhcp
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"net"
+)
+
+// a Policy restricts which clients a Server will pass to its handler
+// on one subnet or interface. the subnet of a message is that of its
+// giaddr if it was relayed, otherwise that of the address it arrived
+// on. the ciaddr of a message is not used, as the client chooses it.
+// the address a message arrived on is only known when the Server is
+// bound to a single address or the platform reports it (Linux), and
+// the interface only in the latter case. otherwise policies with a
+// Subnet or Interface never match messages from direct clients.
+//
+// a Server checks each message against the first of its policies
+// whose Subnet contains the address and whose Interface is the one
+// the message arrived on, and drops it if it is not allowed. messages
+// which match no policy are handled as set by SetUnmatchedPolicy
+type Policy struct {
+	Subnet    *net.IPNet // nil matches every subnet
+	Interface string     // name of the receiving interface, empty matches all
+
+	Classes   []string           // allowed vendor classes (option 60)
+	Relays    []net.IP           // allowed relay addresses
+	AllowMACs []net.HardwareAddr // allowed client hardware addresses
+	DenyMACs  []net.HardwareAddr // denied client hardware addresses
+}
+
+// PolicyDefault selects what a Server does with messages that match
+// none of its policies. it has no effect if there are no policies
+type PolicyDefault int
+
+const (
+	// messages matching no policy are dropped
+	DenyUnmatched PolicyDefault = iota
+	// messages matching no policy are passed to the handler
+	AllowUnmatched
+)
+
+// check a message req against the first matching policy, where
+// local is the address it arrived on and ifindex the index of the
+// interface, which are nil and 0 if they are not known
+func checkPolicies(policies []Policy, unmatched PolicyDefault, req *Msg, local net.IP, ifindex int) error {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	addr := local
+	if req.Giaddr != nil && !req.Giaddr.IsUnspecified() {
+		addr = req.Giaddr
+	}
+	if addr != nil && addr.IsUnspecified() {
+		addr = nil // bound to the wildcard address
+	}
+
+	ifname, looked := "", false
+	for i := range policies {
+		p := &policies[i]
+		if p.Subnet != nil && (addr == nil || !p.Subnet.Contains(addr)) {
+			continue
+		}
+		if p.Interface != "" {
+			if !looked && ifindex > 0 {
+				if ifi, err := net.InterfaceByIndex(ifindex); err == nil {
+					ifname = ifi.Name
+				}
+			}
+			looked = true
+			if p.Interface != ifname {
+				continue
+			}
+		}
+		return p.allow(req)
+	}
+
+	if unmatched == AllowUnmatched {
+		return nil
+	}
+	return errors.New("no policy matches the message")
+}
+
+// check whether a message req is allowed by the policy
+func (p *Policy) allow(req *Msg) error {
+	if len(p.Classes) > 0 {
+		class, _ := req.VendorClassIdentifier()
+		ok := false
+		for _, c := range p.Classes {
+			ok = ok || c == class
+		}
+		if !ok {
+			return errors.Errorf("vendor class %q not allowed", class)
+		}
+	}
+
+	if len(p.Relays) > 0 && req.Giaddr != nil && !req.Giaddr.IsUnspecified() {
+		ok := false
+		for _, r := range p.Relays {
+			ok = ok || r.Equal(req.Giaddr)
+		}
+		if !ok {
+			return errors.Errorf("relay %s not allowed", req.Giaddr)
+		}
+	}
+
+	for _, mac := range p.DenyMACs {
+		if bytes.Equal(mac, req.Chaddr) {
+			return errors.Errorf("client %s denied", req.Chaddr)
+		}
+	}
+	if len(p.AllowMACs) > 0 {
+		ok := false
+		for _, mac := range p.AllowMACs {
+			ok = ok || bytes.Equal(mac, req.Chaddr)
+		}
+		if !ok {
+			return errors.Errorf("client %s not allowed", req.Chaddr)
+		}
+	}
+
+	return nil
+}
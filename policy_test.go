@@ -0,0 +1,139 @@
+package jdhcp
+
+import (
+	"net"
+	"testing"
+)
+
+var (
+	testMAC        = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	otherMAC       = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}
+	_, relayNet, _ = net.ParseCIDR("10.0.0.0/24")
+)
+
+var policyCases = []struct {
+	policies []Policy
+	giaddr   net.IP
+	chaddr   net.HardwareAddr
+	class    string
+	ok       bool
+}{
+	// 0 no policies
+	{nil, net.IPv4zero, testMAC, "", true},
+	// 1 allowed class
+	{[]Policy{{Classes: []string{"PXEClient"}}}, net.IPv4zero, testMAC, "PXEClient", true},
+	// 2 other class
+	{[]Policy{{Classes: []string{"PXEClient"}}}, net.IPv4zero, testMAC, "MSFT 5.0", false},
+	// 3 allowed relay
+	{[]Policy{{Relays: []net.IP{testRelay}}}, testRelay, testMAC, "", true},
+	// 4 other relay
+	{[]Policy{{Relays: []net.IP{testRelay}}}, net.IPv4(10, 0, 0, 2), testMAC, "", false},
+	// 5 denied mac
+	{[]Policy{{DenyMACs: []net.HardwareAddr{testMAC}}}, net.IPv4zero, testMAC, "", false},
+	// 6 mac not in allow list
+	{[]Policy{{AllowMACs: []net.HardwareAddr{otherMAC}}}, net.IPv4zero, testMAC, "", false},
+	// 7 policy for another subnet, unmatched messages are denied
+	{[]Policy{{Subnet: relayNet, AllowMACs: []net.HardwareAddr{testMAC}}}, net.IPv4zero, testMAC, "", false},
+	// 8 first matching subnet is used
+	{[]Policy{
+		{Subnet: relayNet, DenyMACs: []net.HardwareAddr{testMAC}},
+		{AllowMACs: []net.HardwareAddr{testMAC}},
+	}, testRelay, testMAC, "", false},
+}
+
+func TestCheckPolicies(t *testing.T) {
+	for i, tc := range policyCases {
+		req := NewMsg()
+		req.Giaddr = tc.giaddr
+		req.Chaddr = tc.chaddr
+		if tc.class != "" {
+			req.Options[OptionVendorClassIdentifier] = []byte(tc.class)
+		}
+
+		err := checkPolicies(tc.policies, DenyUnmatched, req, testAddr, 0)
+		if tc.ok && err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestCheckPoliciesUnmatched(t *testing.T) {
+	policies := []Policy{{Subnet: relayNet, DenyMACs: []net.HardwareAddr{testMAC}}}
+	req := NewMsg()
+	req.Chaddr = testMAC
+
+	if err := checkPolicies(policies, DenyUnmatched, req, testAddr, 0); err == nil {
+		t.Errorf("expected unmatched message to be denied")
+	}
+	if err := checkPolicies(policies, AllowUnmatched, req, testAddr, 0); err != nil {
+		t.Errorf("expected unmatched message to be allowed, got %s", err)
+	}
+}
+
+func TestCheckPoliciesCiaddr(t *testing.T) {
+	// a direct client cannot pick the policy of another subnet
+	// by setting its ciaddr
+	policies := []Policy{
+		{Subnet: relayNet},
+		{DenyMACs: []net.HardwareAddr{testMAC}},
+	}
+	req := NewMsg()
+	req.Chaddr = testMAC
+	req.Ciaddr = net.IPv4(10, 0, 0, 5)
+
+	if err := checkPolicies(policies, AllowUnmatched, req, testAddr, 0); err == nil {
+		t.Errorf("expected the policy to be chosen by the local address")
+	}
+	if err := checkPolicies(policies, AllowUnmatched, req, net.IPv4(10, 0, 0, 254), 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestCheckPoliciesWildcard(t *testing.T) {
+	// bound to the wildcard address without packet info, the subnet
+	// of a direct client is unknown, even to a policy for 0.0.0.0/0
+	_, all, _ := net.ParseCIDR("0.0.0.0/0")
+	policies := []Policy{{Subnet: all}}
+	req := NewMsg()
+	req.Chaddr = testMAC
+
+	if err := checkPolicies(policies, DenyUnmatched, req, net.IPv4zero, 0); err == nil {
+		t.Errorf("expected direct client to match no policy")
+	}
+	if err := checkPolicies(policies, DenyUnmatched, req, nil, 0); err == nil {
+		t.Errorf("expected direct client to match no policy")
+	}
+
+	// relayed messages are still matched by their giaddr
+	req.Giaddr = testRelay
+	if err := checkPolicies(policies, DenyUnmatched, req, net.IPv4zero, 0); err != nil {
+		t.Errorf("unexpected error for relayed message: %s", err)
+	}
+}
+
+func TestCheckPoliciesInterface(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	policies := []Policy{
+		{Interface: "lo", DenyMACs: []net.HardwareAddr{testMAC}},
+		{Interface: "no-such-if0"},
+	}
+	req := NewMsg()
+	req.Chaddr = testMAC
+
+	if err := checkPolicies(policies, AllowUnmatched, req, testAddr, lo.Index); err == nil {
+		t.Errorf("expected the policy of lo to deny the client")
+	}
+	if err := checkPolicies(policies, DenyUnmatched, req, testAddr, 0); err == nil {
+		t.Errorf("expected no policy to match an unknown interface")
+	}
+	req.Chaddr = otherMAC
+	if err := checkPolicies(policies, DenyUnmatched, req, testAddr, lo.Index); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,66 @@
+package jdhcp
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maximum size of a datagram read by the Server
+const readBufSize = 4096
+
+// pools used to recycle the structures needed in the server
+// path, so that steady-state serving produces very little garbage
+var (
+	optionsPool = sync.Pool{New: func() interface{} { return make(Options) }}
+	msgPool     = sync.Pool{New: func() interface{} { return new(Msg) }}
+	bufferPool  = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	readPool    = sync.Pool{New: func() interface{} {
+		b := make([]byte, readBufSize)
+		return &b
+	}}
+)
+
+// get an empty Options from the pool. it should be handed back
+// with ReleaseOptions once it is no longer needed
+func AcquireOptions() Options {
+	return optionsPool.Get().(Options)
+}
+
+// clear an Options and return it to the pool. the Options
+// must not be used by the caller after it has been released
+func ReleaseOptions(o Options) {
+	if o == nil {
+		return
+	}
+	for k := range o {
+		delete(o, k)
+	}
+	optionsPool.Put(o)
+}
+
+// get a blank Msg from the pool, initialised in the same way as
+// NewMsg. it should be handed back with ReleaseMsg once it is no
+// longer needed
+func AcquireMsg() *Msg {
+	m := acquireMsg()
+	m.init()
+	return m
+}
+
+// clear a Msg and return it, along with its Options, to the pool.
+// the Msg must not be used by the caller after it has been released
+func ReleaseMsg(m *Msg) {
+	if m == nil {
+		return
+	}
+	ReleaseOptions(m.Options)
+	*m = Msg{}
+	msgPool.Put(m)
+}
+
+// get a zeroed Msg with empty Options from the pool
+func acquireMsg() *Msg {
+	m := msgPool.Get().(*Msg)
+	m.Options = AcquireOptions()
+	return m
+}
@@ -0,0 +1,52 @@
+package jdhcp
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestAcquireMsg(t *testing.T) {
+	m := AcquireMsg()
+	defer ReleaseMsg(m)
+
+	if diff := cmp.Diff(NewMsg(), m); diff != "" {
+		t.Fatalf("acquired msg is not blank: %s", diff)
+	}
+}
+
+func TestReleaseMsg(t *testing.T) {
+	m := AcquireMsg()
+	m.Xid = 0x12345678
+	m.Options[OptionDHCPMessageType] = []byte{byte(Discover)}
+	ReleaseMsg(m)
+
+	if m.Xid != 0 || m.Chaddr != nil || m.Options != nil {
+		t.Fatalf("released msg was not cleared: %+v", m)
+	}
+}
+
+func TestReleaseOptions(t *testing.T) {
+	o := AcquireOptions()
+	o[OptionDHCPMessageType] = []byte{byte(Discover)}
+	ReleaseOptions(o)
+
+	if len(o) != 0 {
+		t.Fatalf("released options were not cleared: %v", o)
+	}
+}
+
+func TestParseMsgInto(t *testing.T) {
+	for i, tc := range messageParseCases {
+		got := acquireMsg()
+		err := parseMsgInto(got, tc.asBytes, ParseLimits{})
+		if err != nil {
+			t.Errorf("case %d returned error: %s", i, err)
+			continue
+		}
+
+		if diff := cmp.Diff(tc.asStruct, got); diff != "" {
+			t.Errorf("case %d expected struct does not match result: %s", i, diff)
+		}
+		ReleaseMsg(got)
+	}
+}
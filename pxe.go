@@ -0,0 +1,139 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// sub-options of option 43 used by PXE clients (PXE specification 2.1)
+const (
+	pxeDiscoveryControl = 6
+	pxeMulticastAddr    = 7
+	pxeBootServers      = 8
+	pxeBootMenu         = 9
+	pxeMenuPrompt       = 10
+)
+
+// bits of PXEMenu.DiscoveryControl
+const (
+	PXEDisableBroadcast = 1 << 0 // do not discover boot servers by broadcast
+	PXEDisableMulticast = 1 << 1 // do not discover boot servers by multicast
+	PXEServerListOnly   = 1 << 2 // only use the servers in BootServers
+	PXEUseBootfile      = 1 << 3 // download the boot file without discovery
+)
+
+// a PXEBootServer lists the servers of one boot server type
+type PXEBootServer struct {
+	Type  uint16
+	Addrs []net.IP
+}
+
+// a PXEMenuItem is one entry of the boot menu, which boots
+// from the servers of its Type
+type PXEMenuItem struct {
+	Type        uint16
+	Description string
+}
+
+// a PXEMenu describes the boot menu shown by a PXE client, and
+// where it looks for the servers of each menu item. the result of
+// MarshalBytes is sent in option 43, and option 60 of the response
+// should be set to "PXEClient" so the client looks at it
+type PXEMenu struct {
+	DiscoveryControl byte
+	MulticastAddr    net.IP // nil if multicast discovery is not used
+	BootServers      []PXEBootServer
+	Items            []PXEMenuItem
+
+	// the prompt is shown for Timeout seconds, after which the first
+	// item is booted. 255 waits for a key press, and 0 boots the first
+	// item straight away. there is no prompt if Prompt is empty
+	Prompt  string
+	Timeout byte
+}
+
+// encode the menu as the sub-options of option 43
+func (p *PXEMenu) MarshalBytes() ([]byte, error) {
+	var b []byte
+	add := func(code byte, v []byte) error {
+		if len(v) > 255 {
+			return errors.Errorf("PXE sub-option %d is too long", code)
+		}
+		b = append(append(b, code, byte(len(v))), v...)
+		return nil
+	}
+
+	err := add(pxeDiscoveryControl, []byte{p.DiscoveryControl})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.MulticastAddr != nil {
+		ip4 := p.MulticastAddr.To4()
+		if ip4 == nil {
+			return nil, errors.Wrapf(ErrNotIPv4, "%s", p.MulticastAddr)
+		}
+		err = add(pxeMulticastAddr, ip4)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.BootServers) > 0 {
+		var v []byte
+		for _, s := range p.BootServers {
+			v = append(v, byte(s.Type>>8), byte(s.Type))
+			v = append(v, byte(len(s.Addrs)))
+			for _, ip := range s.Addrs {
+				ip4 := ip.To4()
+				if ip4 == nil {
+					return nil, errors.Wrapf(ErrNotIPv4, "%s", ip)
+				}
+				v = append(v, ip4...)
+			}
+		}
+		err = add(pxeBootServers, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.Items) > 0 {
+		var v []byte
+		for _, item := range p.Items {
+			if len(item.Description) > 255 {
+				return nil, errors.Errorf("PXE menu item %q is too long", item.Description)
+			}
+			v = append(v, byte(item.Type>>8), byte(item.Type))
+			v = append(v, byte(len(item.Description)))
+			v = append(v, item.Description...)
+		}
+		err = add(pxeBootMenu, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Prompt != "" {
+		err = add(pxeMenuPrompt, append([]byte{p.Timeout}, p.Prompt...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b = append(b, byte(OptionEnd))
+	if len(b) > 255 {
+		return nil, errors.New("PXE menu does not fit in option 43")
+	}
+	return b, nil
+}
+
+// set option 43 to a PXE boot menu
+func (o Options) SetPXEMenu(p *PXEMenu) error {
+	v, err := p.MarshalBytes()
+	if err != nil {
+		return &OptionError{OptionVendorSpecificInformation, err}
+	}
+	o[OptionVendorSpecificInformation] = v
+	return nil
+}
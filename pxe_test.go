@@ -0,0 +1,58 @@
+package jdhcp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPXEMenu(t *testing.T) {
+	menu := &PXEMenu{
+		DiscoveryControl: PXEDisableMulticast | PXEServerListOnly,
+		BootServers: []PXEBootServer{
+			{Type: 0x8000, Addrs: []net.IP{net.IPv4(10, 0, 0, 5)}},
+		},
+		Items: []PXEMenuItem{
+			{Type: 0, Description: "Local"},
+			{Type: 0x8000, Description: "Install"},
+		},
+		Prompt:  "Boot:",
+		Timeout: 10,
+	}
+
+	o := make(Options)
+	err := o.SetPXEMenu(menu)
+	if err != nil {
+		t.Fatalf("o.SetPXEMenu() returned error: %s", err)
+	}
+
+	expected := []byte{
+		6, 1, 0x06,
+		8, 7, 0x80, 0x00, 1, 10, 0, 0, 5,
+		9, 18, 0x00, 0x00, 5, 'L', 'o', 'c', 'a', 'l',
+		0x80, 0x00, 7, 'I', 'n', 's', 't', 'a', 'l', 'l',
+		10, 6, 10, 'B', 'o', 'o', 't', ':',
+		255,
+	}
+	if !bytes.Equal(o[OptionVendorSpecificInformation], expected) {
+		t.Errorf("expected %v got %v", expected, o[OptionVendorSpecificInformation])
+	}
+
+	// the result can be read back by the verbose decoder
+	node := decodeOption(OptionVendorSpecificInformation, o[OptionVendorSpecificInformation])
+	if len(node.Children) != 4 {
+		t.Errorf("expected 4 sub-options, got %s", node)
+	}
+}
+
+func TestPXEMenuTooLong(t *testing.T) {
+	menu := &PXEMenu{}
+	for i := 0; i < 10; i++ {
+		menu.Items = append(menu.Items, PXEMenuItem{Description: strings.Repeat("x", 30)})
+	}
+	_, err := menu.MarshalBytes()
+	if err == nil {
+		t.Error("expected an error for a menu which does not fit")
+	}
+}
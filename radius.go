@@ -0,0 +1,295 @@
+package jdhcp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// RADIUS packet codes and attributes (RFC2865, RFC2868 and RFC3579)
+const (
+	radiusAccessRequest = 1
+	radiusAccessAccept  = 2
+	radiusAccessReject  = 3
+
+	radiusUserName             = 1
+	radiusUserPassword         = 2
+	radiusFramedIPAddress      = 8
+	radiusSessionTimeout       = 27
+	radiusCallingStationID     = 31
+	radiusNASIdentifier        = 32
+	radiusMessageAuthenticator = 80
+	radiusTunnelPrivateGroupID = 81
+
+	radiusHeaderLen = 20
+)
+
+// a RADIUSClient asks a RADIUS server whether a client may be given
+// an address, using MAC authentication: the Access-Request has the
+// hardware address of the client, as lowercase hex without separators,
+// as both the User-Name and the User-Password
+type RADIUSClient struct {
+	Addr          string        // host:port of the server, usually port 1812
+	Secret        []byte        // the shared secret
+	NASIdentifier string        // sent as the NAS-Identifier, "jdhcp" if empty
+	Timeout       time.Duration // for each attempt, 3s if zero
+	Retries       int           // attempts after the first
+	Logger        *log.Logger   // for replies which are ignored, discarded if nil
+}
+
+// a RADIUSReply is the answer of a RADIUS server about one client.
+// the attributes are left empty or zero if the server did not send them
+type RADIUSReply struct {
+	Accept         bool
+	FramedIP       net.IP        // Framed-IP-Address, the address to give
+	SessionTimeout time.Duration // Session-Timeout, used as the lease time
+	VLAN           string        // Tunnel-Private-Group-ID
+}
+
+// send an Access-Request for the client with hardware address hw and
+// wait for the reply, until ctx is done. the request is only sent again
+// when an attempt times out, other errors such as a refused port are
+// returned
+func (c *RADIUSClient) Authorize(ctx context.Context, hw net.HardwareAddr) (*RADIUSReply, error) {
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to radius server")
+	}
+	defer conn.Close()
+
+	req, err := c.accessRequest(hw)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+	lg := c.Logger
+	if lg == nil {
+		lg = log.New(io.Discard, "", 0)
+	}
+	// stop waiting once ctx is done
+	defer watchContext(ctx, conn)()
+
+	buf := make([]byte, 4096)
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, errors.Wrap(err, "send access-request")
+		}
+
+		// the deadline of the attempt replaces one set by the
+		// watcher, so ctx is checked again once it is set
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "wait for radius reply")
+		}
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if ctx.Err() != nil {
+					return nil, errors.Wrap(ctx.Err(), "wait for radius reply")
+				}
+				if errors.As(err, &ne) && ne.Timeout() {
+					break // try again
+				}
+				return nil, errors.Wrap(err, "read radius reply")
+			}
+			// ignore replies to other requests
+			if n < radiusHeaderLen || buf[1] != req[1] {
+				continue
+			}
+			// the identifier is easily guessed, so a reply which
+			// fails the checks may be forged and the real one can
+			// still arrive before the deadline
+			r, err := c.parseReply(buf[:n], req)
+			if err != nil {
+				lg.Printf("ignoring reply from radius server %s: %s", c.Addr, err)
+				continue
+			}
+			return r, nil
+		}
+	}
+	return nil, errors.New("no valid reply from radius server")
+}
+
+// build an Access-Request for the client with hardware address hw
+func (c *RADIUSClient) accessRequest(hw net.HardwareAddr) ([]byte, error) {
+	p := make([]byte, radiusHeaderLen, 128)
+	p[0] = radiusAccessRequest
+	if _, err := rand.Read(p[1:radiusHeaderLen]); err != nil {
+		return nil, errors.Wrap(err, "generate request authenticator")
+	}
+	auth := p[4:radiusHeaderLen]
+
+	nas := c.NASIdentifier
+	if nas == "" {
+		nas = "jdhcp"
+	}
+	user := hex.EncodeToString(hw)
+	station := strings.ToUpper(strings.Replace(hw.String(), ":", "-", -1))
+
+	p = appendAttr(p, radiusUserName, []byte(user))
+	p = appendAttr(p, radiusUserPassword, c.hidePassword([]byte(user), auth))
+	p = appendAttr(p, radiusCallingStationID, []byte(station))
+	p = appendAttr(p, radiusNASIdentifier, []byte(nas))
+
+	// the Message-Authenticator is computed with itself zeroed
+	p = appendAttr(p, radiusMessageAuthenticator, make([]byte, md5.Size))
+	binary.BigEndian.PutUint16(p[2:4], uint16(len(p)))
+	mac := hmac.New(md5.New, c.Secret)
+	mac.Write(p)
+	copy(p[len(p)-md5.Size:], mac.Sum(nil))
+	return p, nil
+}
+
+// hide a User-Password with the shared secret and request
+// authenticator auth (RFC2865 chapter 5.2)
+func (c *RADIUSClient) hidePassword(password, auth []byte) []byte {
+	n := (len(password) + 15) / 16 * 16
+	if n == 0 {
+		n = 16
+	}
+	out := make([]byte, n)
+	copy(out, password)
+
+	prev := auth
+	for i := 0; i < n; i += 16 {
+		h := md5.New()
+		h.Write(c.Secret)
+		h.Write(prev)
+		b := h.Sum(nil)
+		for j := range b {
+			out[i+j] ^= b[j]
+		}
+		prev = out[i : i+16]
+	}
+	return out
+}
+
+// check the reply p to the Access-Request req and get its attributes
+func (c *RADIUSClient) parseReply(p, req []byte) (*RADIUSReply, error) {
+	length := int(binary.BigEndian.Uint16(p[2:4]))
+	if length < radiusHeaderLen || length > len(p) {
+		return nil, errors.Errorf("radius reply length %d does not match %d bytes", length, len(p))
+	}
+	p = p[:length]
+
+	// the response authenticator covers the reply with the request
+	// authenticator in its place, followed by the secret
+	h := md5.New()
+	h.Write(p[:4])
+	h.Write(req[4:radiusHeaderLen])
+	h.Write(p[radiusHeaderLen:])
+	h.Write(c.Secret)
+	if !hmac.Equal(h.Sum(nil), p[4:radiusHeaderLen]) {
+		return nil, errors.New("bad radius response authenticator")
+	}
+
+	r := &RADIUSReply{}
+	switch p[0] {
+	case radiusAccessAccept:
+		r.Accept = true
+	case radiusAccessReject:
+	default:
+		return nil, errors.Errorf("unexpected radius code %d", p[0])
+	}
+
+	msgAuth := -1
+	for i := radiusHeaderLen; i < len(p); {
+		if i+2 > len(p) || p[i+1] < 2 || i+int(p[i+1]) > len(p) {
+			return nil, errors.Errorf("radius attribute at offset %d truncated", i)
+		}
+		t, v := p[i], p[i+2:i+int(p[i+1])]
+		i += int(p[i+1])
+
+		switch {
+		case t == radiusMessageAuthenticator && len(v) == md5.Size:
+			msgAuth = i - md5.Size
+		case t == radiusFramedIPAddress && len(v) == 4:
+			r.FramedIP = net.IP(append([]byte(nil), v...))
+		case t == radiusSessionTimeout && len(v) == 4:
+			r.SessionTimeout = time.Duration(binary.BigEndian.Uint32(v)) * time.Second
+		case t == radiusTunnelPrivateGroupID && len(v) > 0:
+			// a leading byte below 0x20 is a tag (RFC2868 chapter 3.6)
+			if v[0] < 0x20 {
+				v = v[1:]
+			}
+			r.VLAN = string(v)
+		}
+	}
+
+	// the request has a Message-Authenticator, so the reply must have
+	// one too. the response authenticator alone relies on MD5, which
+	// allows an Access-Accept to be forged (the BlastRADIUS attack).
+	// it is computed in the same way as the response authenticator,
+	// but with itself zeroed
+	if msgAuth < 0 {
+		return nil, errors.New("radius reply has no message authenticator")
+	}
+	q := append([]byte(nil), p...)
+	copy(q[4:radiusHeaderLen], req[4:radiusHeaderLen])
+	copy(q[msgAuth:msgAuth+md5.Size], make([]byte, md5.Size))
+	mac := hmac.New(md5.New, c.Secret)
+	mac.Write(q)
+	if !hmac.Equal(mac.Sum(nil), p[msgAuth:msgAuth+md5.Size]) {
+		return nil, errors.New("bad radius message authenticator")
+	}
+	return r, nil
+}
+
+func appendAttr(p []byte, t byte, v []byte) []byte {
+	p = append(p, t, byte(2+len(v)))
+	return append(p, v...)
+}
+
+type radiusReplyKey struct{}
+
+// get the RADIUSReply for the message being handled by a handler
+// wrapped with RADIUSClient.Handler, or nil if there is none
+func RADIUSReplyFromContext(ctx context.Context) *RADIUSReply {
+	r, _ := ctx.Value(radiusReplyKey{}).(*RADIUSReply)
+	return r
+}
+
+// wrap next so that the client of each DHCPDISCOVER and DHCPREQUEST
+// is authorized first. rejected clients, and those which cannot be
+// authorized, get no response. otherwise the reply is available to
+// next through RADIUSReplyFromContext (such as for its VLAN), and its
+// Framed-IP-Address and Session-Timeout are put in the response, as
+// yiaddr and the lease time, unless next has set them itself
+func (c *RADIUSClient) Handler(next MsgHandler) MsgHandler {
+	return func(ctx context.Context, req Msg) *Msg {
+		if t, _ := req.DHCPMessageType(); t != Discover && t != Request {
+			return next(ctx, req)
+		}
+
+		r, err := c.Authorize(ctx, req.Chaddr)
+		if err != nil || !r.Accept {
+			return nil
+		}
+
+		res := next(context.WithValue(ctx, radiusReplyKey{}, r), req)
+		if res == nil {
+			return nil
+		}
+		if r.FramedIP != nil && (res.Yiaddr == nil || res.Yiaddr.IsUnspecified()) {
+			res.Yiaddr = r.FramedIP
+		}
+		if _, ok := res.Options[OptionIPAddressLeaseTime]; !ok && r.SessionTimeout > 0 {
+			res.SetIPAddressLeaseTime(r.SessionTimeout)
+		}
+		return res
+	}
+}
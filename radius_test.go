@@ -0,0 +1,252 @@
+package jdhcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"net"
+	"testing"
+	"time"
+)
+
+var testRADIUSSecret = []byte("testing123")
+
+// run a RADIUS server which accepts 00:11:22:33:44:55, giving it
+// attrs, and rejects every other client
+func startRADIUS(t *testing.T, attrs []byte) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+
+			// find and recover the password, which is the user name
+			var user, password []byte
+			for i := radiusHeaderLen; i+2 <= len(req); i += int(req[i+1]) {
+				v := req[i+2 : i+int(req[i+1])]
+				switch req[i] {
+				case radiusUserName:
+					user = v
+				case radiusUserPassword:
+					// hiding a single block is its own inverse
+					c := &RADIUSClient{Secret: testRADIUSSecret}
+					password = bytes.TrimRight(c.hidePassword(v, req[4:radiusHeaderLen]), "\x00")
+				}
+			}
+
+			code := byte(radiusAccessReject)
+			var body []byte
+			if string(user) == "001122334455" && bytes.Equal(password, user) {
+				code = radiusAccessAccept
+				body = attrs
+			}
+			conn.WriteTo(radiusReply(code, req, body, true), from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// build a reply to req with the attributes body, signed with the
+// test secret, followed by a Message-Authenticator if msgAuth is set
+func radiusReply(code byte, req, body []byte, msgAuth bool) []byte {
+	res := append([]byte{code, req[1], 0, 0}, req[4:radiusHeaderLen]...)
+	res = append(res, body...)
+	if msgAuth {
+		res = appendAttr(res, radiusMessageAuthenticator, make([]byte, md5.Size))
+	}
+	binary.BigEndian.PutUint16(res[2:4], uint16(len(res)))
+	if msgAuth {
+		mac := hmac.New(md5.New, testRADIUSSecret)
+		mac.Write(res)
+		copy(res[len(res)-md5.Size:], mac.Sum(nil))
+	}
+	h := md5.New()
+	h.Write(res)
+	h.Write(testRADIUSSecret)
+	copy(res[4:radiusHeaderLen], h.Sum(nil))
+	return res
+}
+
+func TestRADIUSAuthorize(t *testing.T) {
+	var attrs []byte
+	attrs = appendAttr(attrs, radiusFramedIPAddress, []byte{10, 0, 0, 42})
+	attrs = appendAttr(attrs, radiusSessionTimeout, []byte{0, 0, 0x0e, 0x10})
+	attrs = appendAttr(attrs, radiusTunnelPrivateGroupID, []byte{1, '1', '0', '0'})
+	c := &RADIUSClient{Addr: startRADIUS(t, attrs), Secret: testRADIUSSecret, Timeout: time.Second}
+
+	r, err := c.Authorize(context.Background(), net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err != nil {
+		t.Fatalf("cannot authorize: %s", err)
+	}
+	if !r.Accept || !r.FramedIP.Equal(net.IPv4(10, 0, 0, 42)) || r.SessionTimeout != time.Hour || r.VLAN != "100" {
+		t.Errorf("unexpected reply %+v", r)
+	}
+
+	r, err = c.Authorize(context.Background(), net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x66})
+	if err != nil {
+		t.Fatalf("cannot authorize: %s", err)
+	}
+	if r.Accept {
+		t.Errorf("expected other client to be rejected")
+	}
+
+	c.Secret = []byte("wrong")
+	c.Timeout = 100 * time.Millisecond
+	_, err = c.Authorize(context.Background(), net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err == nil {
+		t.Errorf("expected error for a reply signed with another secret")
+	}
+}
+
+func TestRADIUSMessageAuthenticator(t *testing.T) {
+	c := &RADIUSClient{Secret: testRADIUSSecret}
+	req, err := c.accessRequest(net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err != nil {
+		t.Fatalf("cannot build request: %s", err)
+	}
+
+	// check the Message-Authenticator of the request
+	q := append([]byte(nil), req...)
+	copy(q[len(q)-md5.Size:], make([]byte, md5.Size))
+	mac := hmac.New(md5.New, testRADIUSSecret)
+	mac.Write(q)
+	if !hmac.Equal(mac.Sum(nil), req[len(req)-md5.Size:]) {
+		t.Errorf("bad message authenticator in request")
+	}
+
+	// a reply with a bad Message-Authenticator is refused
+	res := append([]byte{radiusAccessAccept, req[1], 0, 0}, req[4:radiusHeaderLen]...)
+	res = appendAttr(res, radiusMessageAuthenticator, make([]byte, md5.Size))
+	binary.BigEndian.PutUint16(res[2:4], uint16(len(res)))
+	h := md5.New()
+	h.Write(res)
+	h.Write(testRADIUSSecret)
+	copy(res[4:radiusHeaderLen], h.Sum(nil))
+	if _, err := c.parseReply(res, req); err == nil {
+		t.Errorf("expected error for a bad message authenticator")
+	}
+
+	// as is one without a Message-Authenticator, even with a
+	// good response authenticator
+	res = radiusReply(radiusAccessAccept, req, nil, false)
+	if _, err := c.parseReply(res, req); err == nil {
+		t.Errorf("expected error for a missing message authenticator")
+	}
+	res = radiusReply(radiusAccessAccept, req, nil, true)
+	if r, err := c.parseReply(res, req); err != nil || !r.Accept {
+		t.Errorf("expected a good reply to be accepted, got %+v, %v", r, err)
+	}
+}
+
+func TestRADIUSIgnoresForgedReply(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer conn.Close()
+
+	// answer with a forged Access-Reject with the right identifier
+	// before the real Access-Accept
+	go func() {
+		buf := make([]byte, 4096)
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		forged := radiusReply(radiusAccessReject, req, nil, true)
+		forged[4] ^= 0xff
+		conn.WriteTo(forged, from)
+		conn.WriteTo(radiusReply(radiusAccessAccept, req, nil, true), from)
+	}()
+
+	c := &RADIUSClient{Addr: conn.LocalAddr().String(), Secret: testRADIUSSecret, Timeout: time.Second}
+	r, err := c.Authorize(context.Background(), net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err != nil {
+		t.Fatalf("cannot authorize: %s", err)
+	}
+	if !r.Accept {
+		t.Errorf("expected the real reply to be used")
+	}
+}
+
+func TestRADIUSAuthorizeErrors(t *testing.T) {
+	hw := net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	// a refused request is not retried, its error is returned
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	c := &RADIUSClient{Addr: addr, Secret: testRADIUSSecret, Timeout: time.Second, Retries: 3}
+	_, err = c.Authorize(context.Background(), hw)
+	var oe *net.OpError
+	if !errors.As(err, &oe) {
+		t.Errorf("expected the read error when the server port is closed, got %v", err)
+	}
+
+	// a cancelled ctx without a deadline stops the wait
+	conn, err = net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	defer conn.Close()
+	c = &RADIUSClient{Addr: conn.LocalAddr().String(), Secret: testRADIUSSecret, Timeout: 5 * time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = c.Authorize(ctx, hw)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected Authorize to return once ctx is cancelled, took %s", d)
+	}
+}
+
+func TestRADIUSHandler(t *testing.T) {
+	var attrs []byte
+	attrs = appendAttr(attrs, radiusFramedIPAddress, []byte{10, 0, 0, 42})
+	attrs = appendAttr(attrs, radiusSessionTimeout, []byte{0, 0, 0x0e, 0x10})
+	c := &RADIUSClient{Addr: startRADIUS(t, attrs), Secret: testRADIUSSecret, Timeout: time.Second}
+
+	h := c.Handler(func(ctx context.Context, req Msg) *Msg {
+		if RADIUSReplyFromContext(ctx) == nil {
+			t.Errorf("expected a radius reply in the context")
+		}
+		return NewMsg()
+	})
+
+	req := NewMsg()
+	req.Chaddr = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+	req.Options[OptionDHCPMessageType] = []byte{byte(Discover)}
+	res := h(context.Background(), *req)
+	if res == nil {
+		t.Fatalf("expected a response")
+	}
+	if !res.Yiaddr.Equal(net.IPv4(10, 0, 0, 42)) {
+		t.Errorf("expected yiaddr 10.0.0.42 got %s", res.Yiaddr)
+	}
+	if d, _ := res.IPAddressLeaseTime(); d != time.Hour {
+		t.Errorf("expected lease time 1h got %s", d)
+	}
+
+	req.Chaddr = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x66}
+	if res := h(context.Background(), *req); res != nil {
+		t.Errorf("expected no response for a rejected client")
+	}
+}
@@ -0,0 +1,120 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// sub-options of option 82 with a field in RelayAgentInfo
+const (
+	relayCircuitID     = 1   // RFC3046
+	relayRemoteID      = 2   // RFC3046
+	relayLinkSelection = 5   // RFC3527
+	relaySubscriberID  = 6   // RFC3993
+	relayVSSSubOption  = 151 // RFC6607
+)
+
+// a RelayAgentInfo is the content of option 82, added to requests by a
+// relay agent and echoed back by the server. fields are left empty if
+// their sub-option is not present
+type RelayAgentInfo struct {
+	CircuitID     []byte // the port or circuit the request came in on
+	RemoteID      []byte // the remote end of the circuit, often a modem
+	LinkSelection net.IP // the subnet to allocate from, if not that of giaddr
+	SubscriberID  string // the subscriber, as configured on the relay
+	VSS           []byte // Virtual Subnet Selection, including its type byte
+
+	// sub-options without a field, by code
+	Other VendorOptions
+}
+
+// parse the value of option 82
+func ParseRelayAgentInfo(data []byte) (*RelayAgentInfo, error) {
+	sub, err := ParseVendorOptions(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RelayAgentInfo{}
+	for code, v := range sub {
+		switch code {
+		case relayCircuitID:
+			r.CircuitID = v
+		case relayRemoteID:
+			r.RemoteID = v
+		case relayLinkSelection:
+			if len(v) != 4 {
+				return nil, errors.Errorf("link selection length %d is not 4", len(v))
+			}
+			r.LinkSelection = net.IP(v)
+		case relaySubscriberID:
+			r.SubscriberID = string(v)
+		case relayVSSSubOption:
+			r.VSS = v
+		default:
+			if r.Other == nil {
+				r.Other = make(VendorOptions)
+			}
+			r.Other[code] = v
+		}
+	}
+	return r, nil
+}
+
+// encode the sub-options sorted by code. unlike option 43, option 82
+// is not ended with End
+func (r *RelayAgentInfo) MarshalBytes() ([]byte, error) {
+	sub := make(VendorOptions, len(r.Other)+5)
+	for code, v := range r.Other {
+		sub[code] = v
+	}
+	if len(r.CircuitID) > 0 {
+		sub[relayCircuitID] = r.CircuitID
+	}
+	if len(r.RemoteID) > 0 {
+		sub[relayRemoteID] = r.RemoteID
+	}
+	if r.LinkSelection != nil {
+		ip4 := r.LinkSelection.To4()
+		if ip4 == nil {
+			return nil, errors.Wrapf(ErrNotIPv4, "link selection %s", r.LinkSelection)
+		}
+		sub[relayLinkSelection] = ip4
+	}
+	if r.SubscriberID != "" {
+		sub[relaySubscriberID] = []byte(r.SubscriberID)
+	}
+	if len(r.VSS) > 0 {
+		sub[relayVSSSubOption] = r.VSS
+	}
+
+	data := sub.MarshalBytes()
+	return data[:len(data)-1], nil
+}
+
+// option 82
+func (o Options) RelayAgentInfo() (*RelayAgentInfo, error) {
+	data, ok := o[OptionRelayAgentInformation]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	r, err := ParseRelayAgentInfo(data)
+	if err != nil {
+		return nil, &OptionError{OptionRelayAgentInformation, err}
+	}
+	return r, nil
+}
+
+// set option 82
+func (o Options) SetRelayAgentInfo(r *RelayAgentInfo) error {
+	data, err := r.MarshalBytes()
+	if err != nil {
+		return &OptionError{OptionRelayAgentInformation, err}
+	}
+	if len(data) == 0 || len(data) > 255 {
+		return &OptionError{OptionRelayAgentInformation,
+			errors.Errorf("sub-options need %d bytes, not between 1 and 255", len(data))}
+	}
+	o[OptionRelayAgentInformation] = data
+	return nil
+}
@@ -0,0 +1,54 @@
+package jdhcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestRelayAgentInfo(t *testing.T) {
+	o := make(Options)
+	r1 := &RelayAgentInfo{
+		CircuitID:     []byte("eth0"),
+		RemoteID:      []byte{0xab, 0xcd},
+		LinkSelection: net.IPv4(10, 2, 0, 0),
+		SubscriberID:  "sub1",
+		Other:         VendorOptions{9: {1, 2}},
+	}
+	err := o.SetRelayAgentInfo(r1)
+	if err != nil {
+		t.Fatalf("o.SetRelayAgentInfo() returned error: %s", err)
+	}
+	want := []byte{
+		1, 4, 'e', 't', 'h', '0',
+		2, 2, 0xab, 0xcd,
+		5, 4, 10, 2, 0, 0,
+		6, 4, 's', 'u', 'b', '1',
+		9, 2, 1, 2,
+	}
+	if !bytes.Equal(o[OptionRelayAgentInformation], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionRelayAgentInformation])
+	}
+
+	r2, err := o.RelayAgentInfo()
+	if err != nil {
+		t.Fatalf("o.RelayAgentInfo() returned error: %s", err)
+	}
+	if string(r2.CircuitID) != "eth0" || !bytes.Equal(r2.RemoteID, r1.RemoteID) ||
+		!r2.LinkSelection.Equal(r1.LinkSelection) || r2.SubscriberID != "sub1" ||
+		r2.VSS != nil || !bytes.Equal(r2.Other[9], []byte{1, 2}) {
+		t.Errorf("unexpected relay agent info %+v", r2)
+	}
+
+	for i, v := range [][]byte{
+		{1, 5, 'e', 't', 'h', '0'},
+		{5, 2, 10, 2},
+	} {
+		if _, err := ParseRelayAgentInfo(v); err == nil {
+			t.Errorf("%d: expected error for % x", i, v)
+		}
+	}
+	if err := o.SetRelayAgentInfo(&RelayAgentInfo{}); err == nil {
+		t.Errorf("expected error for empty relay agent info")
+	}
+}
@@ -0,0 +1,149 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// a Route is one entry of the classless static route option
+type Route struct {
+	Dest   *net.IPNet
+	Router net.IP
+}
+
+// a StaticRoute is one entry of the static route option, a route to
+// a host, or to the classful network (RFC791) of Dest
+type StaticRoute struct {
+	Dest   net.IP
+	Router net.IP
+}
+
+// option 33, superseded by option 121. a client ignores this if
+// both are present (RFC3442 chapter 3)
+func (o Options) StaticRoutes() ([]StaticRoute, error) {
+	ips, err := o.IPs(OptionStaticRoute)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips)%2 != 0 {
+		return nil, &OptionError{OptionStaticRoute, errors.Errorf("%d addresses do not make pairs", len(ips))}
+	}
+
+	routes := make([]StaticRoute, 0, len(ips)/2)
+	for i := 0; i < len(ips); i += 2 {
+		routes = append(routes, StaticRoute{ips[i], ips[i+1]})
+	}
+	return routes, nil
+}
+
+// set option 33. a default route cannot be given with it
+// (RFC2132 chapter 5.8), the router option is for that
+func (o Options) SetStaticRoutes(routes []StaticRoute) error {
+	ips := make([]net.IP, 0, 2*len(routes))
+	for _, r := range routes {
+		if r.Dest.Equal(net.IPv4zero) {
+			return &OptionError{OptionStaticRoute, errors.New("default route is not allowed")}
+		}
+		ips = append(ips, r.Dest, r.Router)
+	}
+	return o.SetIPs(OptionStaticRoute, ips)
+}
+
+// option 121. if this is present, a client ignores the router
+// option, so any default route must be included here as well
+func (o Options) ClasslessStaticRoutes() ([]Route, error) {
+	return o.routes(OptionClasslessStaticRoute)
+}
+
+// set option 121
+func (o Options) SetClasslessStaticRoutes(routes []Route) error {
+	return o.setRoutes(routes, OptionClasslessStaticRoute)
+}
+
+// option 249, which Windows clients before Vista request
+// instead of option 121. it has the same format
+func (o Options) MSClasslessStaticRoutes() ([]Route, error) {
+	return o.routes(OptionMSClasslessStaticRoute)
+}
+
+// set option 249
+func (o Options) SetMSClasslessStaticRoutes(routes []Route) error {
+	return o.setRoutes(routes, OptionMSClasslessStaticRoute)
+}
+
+// set both options 121 and 249 to the same routes, so that every
+// client gets them whichever of the two it understands
+func (o Options) SetMirroredClasslessStaticRoutes(routes []Route) error {
+	return o.setRoutes(routes, OptionClasslessStaticRoute, OptionMSClasslessStaticRoute)
+}
+
+// decode a classless static route option (RFC3442 chapter 4)
+func (o Options) routes(code OptionCode) ([]Route, error) {
+	v, ok := o[code]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+
+	var routes []Route
+	for i := 0; i < len(v); {
+		width := int(v[i])
+		if width > 32 {
+			return nil, &OptionError{code, errors.Errorf("invalid route destination width %d", width)}
+		}
+		octets := (width + 7) / 8
+		if i+1+octets+4 > len(v) {
+			return nil, &OptionError{code, errors.Wrapf(ErrShortRead, "route %d", len(routes))}
+		}
+
+		dest := make(net.IP, 4)
+		copy(dest, v[i+1:i+1+octets])
+		i += 1 + octets
+		router := net.IP(v[i : i+4])
+		i += 4
+
+		routes = append(routes, Route{
+			Dest:   &net.IPNet{IP: dest, Mask: net.CIDRMask(width, 32)},
+			Router: router,
+		})
+	}
+	return routes, nil
+}
+
+// encode routes as a classless static route option, storing the
+// result in each of codes
+func (o Options) setRoutes(routes []Route, codes ...OptionCode) error {
+	v, err := encodeRoutes(routes)
+	if err != nil {
+		return &OptionError{codes[0], err}
+	}
+	for _, code := range codes {
+		o[code] = v
+	}
+	return nil
+}
+
+func encodeRoutes(routes []Route) ([]byte, error) {
+	var v []byte
+	for _, r := range routes {
+		if r.Dest == nil {
+			return nil, errors.New("route has no destination")
+		}
+		dest := r.Dest.IP.To4()
+		width, bits := r.Dest.Mask.Size()
+		if dest == nil || bits != 32 {
+			return nil, errors.Wrapf(ErrNotIPv4, "route destination %s", r.Dest)
+		}
+		router := r.Router.To4()
+		if router == nil {
+			return nil, errors.Wrapf(ErrNotIPv4, "route router %s", r.Router)
+		}
+
+		v = append(v, byte(width))
+		v = append(v, dest.Mask(r.Dest.Mask)[:(width+7)/8]...)
+		v = append(v, router...)
+	}
+	if len(v) > 255 {
+		return nil, errors.Errorf("%d routes do not fit in one option", len(routes))
+	}
+	return v, nil
+}
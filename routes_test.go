@@ -0,0 +1,135 @@
+package jdhcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+var routeCases = []struct {
+	routes  []Route
+	asBytes []byte
+}{
+	// 0 default route, which has no destination octets
+	{[]Route{{mustCIDR("0.0.0.0/0"), net.IPv4(10, 0, 0, 1)}},
+		[]byte{0, 10, 0, 0, 1}},
+	// 1 examples from RFC3442 chapter 4
+	{[]Route{
+		{mustCIDR("10.17.0.0/16"), net.IPv4(10, 0, 0, 1)},
+		{mustCIDR("10.27.129.0/24"), net.IPv4(10, 0, 0, 2)},
+		{mustCIDR("10.229.0.128/25"), net.IPv4(10, 0, 0, 3)},
+		{mustCIDR("10.198.122.47/32"), net.IPv4(10, 0, 0, 4)},
+	}, []byte{
+		16, 10, 17, 10, 0, 0, 1,
+		24, 10, 27, 129, 10, 0, 0, 2,
+		25, 10, 229, 0, 128, 10, 0, 0, 3,
+		32, 10, 198, 122, 47, 10, 0, 0, 4,
+	}},
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestClasslessStaticRoutes(t *testing.T) {
+	for i, tc := range routeCases {
+		o := make(Options)
+		err := o.SetClasslessStaticRoutes(tc.routes)
+		if err != nil {
+			t.Fatalf("case %d: o.SetClasslessStaticRoutes() returned error: %s", i, err)
+		}
+		if !bytes.Equal(o[OptionClasslessStaticRoute], tc.asBytes) {
+			t.Errorf("case %d: expected %v got %v", i, tc.asBytes, o[OptionClasslessStaticRoute])
+		}
+
+		got, err := o.ClasslessStaticRoutes()
+		if err != nil {
+			t.Fatalf("case %d: o.ClasslessStaticRoutes() returned error: %s", i, err)
+		}
+		if len(got) != len(tc.routes) {
+			t.Fatalf("case %d: expected %v got %v", i, tc.routes, got)
+		}
+		for j, r := range tc.routes {
+			if r.Dest.String() != got[j].Dest.String() || !r.Router.Equal(got[j].Router) {
+				t.Errorf("case %d: route %d expected %v got %v", i, j, r, got[j])
+			}
+		}
+	}
+}
+
+func TestMirroredClasslessStaticRoutes(t *testing.T) {
+	o := make(Options)
+	err := o.SetMirroredClasslessStaticRoutes(routeCases[1].routes)
+	if err != nil {
+		t.Fatalf("o.SetMirroredClasslessStaticRoutes() returned error: %s", err)
+	}
+	if !bytes.Equal(o[OptionClasslessStaticRoute], o[OptionMSClasslessStaticRoute]) {
+		t.Errorf("options 121 and 249 differ: %v and %v",
+			o[OptionClasslessStaticRoute], o[OptionMSClasslessStaticRoute])
+	}
+
+	routes, err := o.MSClasslessStaticRoutes()
+	if err != nil {
+		t.Fatalf("o.MSClasslessStaticRoutes() returned error: %s", err)
+	}
+	if len(routes) != len(routeCases[1].routes) {
+		t.Errorf("expected %d routes, got %d", len(routeCases[1].routes), len(routes))
+	}
+}
+
+func TestClasslessStaticRoutesInvalid(t *testing.T) {
+	for i, v := range [][]byte{
+		{33, 10, 0, 0, 0, 0, 10, 0, 0, 1}, // too wide
+		{24, 10, 0, 0, 10, 0, 0},          // truncated router
+		{16, 10},                          // truncated destination
+	} {
+		o := Options{OptionClasslessStaticRoute: v}
+		_, err := o.ClasslessStaticRoutes()
+		if err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+
+	o := make(Options)
+	err := o.SetClasslessStaticRoutes([]Route{{mustCIDR("2001:db8::/32"), net.IPv4(10, 0, 0, 1)}})
+	if err == nil {
+		t.Error("expected an error for an IPv6 destination")
+	}
+}
+
+func TestStaticRoutes(t *testing.T) {
+	o := make(Options)
+	err := o.SetStaticRoutes([]StaticRoute{
+		{net.IPv4(10, 0, 0, 0), net.IPv4(192, 168, 0, 1)},
+		{net.IPv4(172, 16, 0, 5), net.IPv4(192, 168, 0, 2)},
+	})
+	if err != nil {
+		t.Fatalf("o.SetStaticRoutes() returned error: %s", err)
+	}
+	want := []byte{10, 0, 0, 0, 192, 168, 0, 1, 172, 16, 0, 5, 192, 168, 0, 2}
+	if !bytes.Equal(o[OptionStaticRoute], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionStaticRoute])
+	}
+
+	routes, err := o.StaticRoutes()
+	if err != nil {
+		t.Fatalf("o.StaticRoutes() returned error: %s", err)
+	}
+	if len(routes) != 2 || !routes[1].Dest.Equal(net.IPv4(172, 16, 0, 5)) ||
+		!routes[1].Router.Equal(net.IPv4(192, 168, 0, 2)) {
+		t.Errorf("unexpected routes %v", routes)
+	}
+
+	o[OptionStaticRoute] = want[:12]
+	if _, err := o.StaticRoutes(); err == nil {
+		t.Errorf("expected error for an odd number of addresses")
+	}
+	err = o.SetStaticRoutes([]StaticRoute{{net.IPv4zero, net.IPv4(192, 168, 0, 1)}})
+	if err == nil {
+		t.Errorf("expected error for a default route")
+	}
+}
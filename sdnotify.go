@@ -0,0 +1,54 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"os"
+)
+
+// send a state change such as "READY=1" or "STOPPING=1" to the service
+// manager (see sd_notify(3)). nothing is sent if this process was not
+// started by systemd with notification enabled
+func SdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if path[0] == '@' {
+		path = "\x00" + path[1:] // abstract namespace
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrap(err, "connect to notify socket")
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// check whether the Server is running and handling messages,
+// returning an error describing the problem if it is not
+func (l *Server) Health() error {
+	if !l.Listening() {
+		return errors.New("server is not running")
+	}
+	select {
+	case <-l.done:
+		return errors.New("server has stopped reading messages")
+	default:
+	}
+	return nil
+}
+
+// tell the service manager that the Server is ready, once it has been
+// started and the handler has everything it needs (such as loaded
+// leases). an error is returned if the Server is not healthy
+func (l *Server) NotifyReady() error {
+	err := l.Health()
+	if err != nil {
+		return err
+	}
+	return SdNotify("READY=1")
+}
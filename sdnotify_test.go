@@ -0,0 +1,56 @@
+package jdhcp
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotifyReady(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify")
+	sock, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("no unix datagram sockets: %s", err)
+	}
+	defer sock.Close()
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	serv := NewServer(WithLogger(testLogg))
+	if serv.NotifyReady() == nil {
+		t.Error("expected a stopped server not to be ready")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+	err = serv.NotifyReady()
+	if err != nil {
+		t.Fatalf("could not notify: %s", err)
+	}
+
+	buf := make([]byte, 64)
+	sock.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := sock.Read(buf)
+	if err != nil {
+		t.Fatalf("did not receive a notification: %s", err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("expected READY=1, got %q", buf[:n])
+	}
+
+	err = serv.Stop()
+	if err != nil {
+		t.Fatalf("could not stop server: %s", err)
+	}
+	if serv.Health() == nil {
+		t.Error("expected a stopped server not to be healthy")
+	}
+}
@@ -6,11 +6,15 @@
 package jdhcp
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/pkg/errors"
+	"io"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,35 +24,100 @@ import (
 // incoming message, and should return a Msg containing the response
 // to be sent. If there is no response needed, the returned Msg
 // should be nil.
+//
+// The received Msg and its Options are recycled once the response
+// has been sent, so the callback must copy any part of it that needs
+// to be kept for longer than that.
 type MsgCallback func(Msg) *Msg
 
+// a MsgHandler is a MsgCallback which is also given a context. the
+// context is cancelled when the Server is stopped, or when the handler
+// runs for longer than the timeout set with SetHandlerTimeout. the
+// Server still waits for the handler to return, as the Msg refers to
+// the buffer the request was read into, so any slow work done by the
+// handler (such as a lease database lookup) must give up once the
+// context is done
+type MsgHandler func(context.Context, Msg) *Msg
+
 // a Server parses incoming DHCP messages and then
 // calls the relevant callbacks with the received information
 // based on the result of the callback, it will send a response
 type Server struct {
-	ctx     context.Context
-	cancel  context.CancelFunc
-	address net.IP
-	port    int
-	socket  *net.UDPConn
-	// socket    net.PacketConn
+	stats Stats // first, for 64-bit alignment of its counters
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	address   net.IP
+	port      int
+	socket    net.PacketConn
 	listening bool
+	over6     bool // messages are carried over DHCPv6
+	pktinfo   bool // the interface of each message is known
+	done      chan struct{}
 	log       *log.Logger
 
-	cbMutex sync.RWMutex
-	msgCb   MsgCallback
+	cbMutex  sync.RWMutex
+	msgCb    MsgHandler
+	order    OptionOrder
+	limits   ParseLimits
+	timeout  time.Duration
+	dropLate bool
+	sources  SourceCheck
+	policies []Policy
+	unmatch  PolicyDefault
+	syslog   *Syslog
+	boot     BootPolicy
+	serverID net.IP
+	hooks    *hookRunner
+	running  hookGroup
+}
+
+// a ServerOption changes the configuration of a Server as it is
+// created by NewServer
+type ServerOption func(*Server)
+
+// set the parent context of the Server, which stops it when cancelled.
+// the default is context.Background()
+func WithContext(ctx context.Context) ServerOption {
+	return func(l *Server) { l.ctx = ctx }
+}
+
+// set the logger of the Server. the default discards all messages
+func WithLogger(lg *log.Logger) ServerOption {
+	return func(l *Server) { l.log = lg }
+}
+
+// set the address Start listens on. the default is 0.0.0.0
+func WithAddress(address net.IP) ServerOption {
+	return func(l *Server) { l.address = address }
+}
+
+// set the UDP port Start listens on. the default is ServerPort
+func WithPort(port int) ServerOption {
+	return func(l *Server) { l.port = port }
 }
 
-// create and initialise a new Server
-func NewServer(ctx context.Context, lg *log.Logger, address net.IP, port int) *Server {
-	ctx, cancel := context.WithCancel(ctx)
-	return &Server{
-		ctx:     ctx,
-		cancel:  cancel,
-		address: address,
-		port:    port,
-		log:     lg,
+// create and initialise a new Server, applying opts to the defaults
+func NewServer(opts ...ServerOption) *Server {
+	l := &Server{
+		ctx:     context.Background(),
+		address: net.IPv4zero,
+		port:    ServerPort,
+		log:     log.New(io.Discard, "", 0),
+		limits:  DefaultParseLimits,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.ctx == nil {
+		l.ctx = context.Background()
+	}
+	if l.log == nil {
+		l.log = log.New(io.Discard, "", 0)
+	}
+
+	l.ctx, l.cancel = context.WithCancel(l.ctx)
+	return l
 }
 
 // begin listening for incoming DHCP messages
@@ -58,32 +127,71 @@ func (l *Server) Start() error {
 		return nil
 	}
 
-	var err error
-	l.socket, err = net.ListenUDP("udp4",
-		&net.UDPAddr{l.address, l.port, ""})
-	// l.socket, err = net.ListenPacket("udp4",
-	// 	fmt.Sprintf("%s:%d", l.address, l.port))
+	socket, err := net.ListenUDP("udp4",
+		&net.UDPAddr{IP: l.address, Port: l.port})
 	if err != nil {
 		return errors.Wrap(err, "open listening socket")
 	}
 
+	l.serve(socket, false)
+	return nil
+}
+
+// begin handling DHCP messages received on an already open conn,
+// instead of opening a socket on the address and port of the Server.
+// the Server takes ownership of conn, and closes it when stopped
+func (l *Server) StartConn(conn net.PacketConn) error {
+	l.log.Print("starting dhcp server")
+	if l.Listening() {
+		return nil
+	}
+
+	l.serve(conn, false)
+	return nil
+}
+
+// begin handling DHCPv4 messages carried over DHCPv6 (RFC7341) which
+// are received on an already open conn, usually a UDP socket on
+// port 547. responses are sent back in the same way, through any
+// DHCPv6 relays the request came through. the Server takes ownership
+// of conn, and closes it when stopped
+func (l *Server) StartConn4o6(conn net.PacketConn) error {
+	l.log.Print("starting dhcp server")
+	if l.Listening() {
+		return nil
+	}
+
+	l.serve(conn, true)
+	return nil
+}
+
+// start the loop reading from conn
+func (l *Server) serve(conn net.PacketConn, over6 bool) {
+	l.socket = conn
+	l.over6 = over6
+	l.pktinfo = !over6 && enablePacketInfo(conn)
 	l.listening = true
+	l.done = make(chan struct{})
 
 	go l.loop()
+	go l.watch()
 
 	l.log.Print("started dhcp server")
-	return nil
 }
 
-// stop the Server and close down all resources
+// stop the Server and close down all resources. this waits for any
+// hook programs still running, which are killed at their timeout
 func (l *Server) Stop() error {
 	l.log.Print("stopping dhcp server")
 	if !l.Listening() {
 		return nil
 	}
 
+	// closing the socket wakes up the loop, which then exits
 	l.cancel()
 	err := l.socket.Close()
+	<-l.done
+	l.running.stop()
 	if err != nil {
 		return err
 	}
@@ -101,63 +209,382 @@ func (l *Server) Listening() bool {
 
 // register a callback with the Server
 func (l *Server) RegisterCallback(cb MsgCallback) {
+	l.RegisterHandler(func(_ context.Context, req Msg) *Msg {
+		return cb(req)
+	})
+}
+
+// register a handler with the Server, replacing any callback
+func (l *Server) RegisterHandler(h MsgHandler) {
+	l.cbMutex.Lock()
+	l.msgCb = h
+	l.cbMutex.Unlock()
+}
+
+// limit how long the handler may take over each message, after which
+// its context is cancelled and the timeout is counted in Stats. the
+// handler is not abandoned, so it must honour the cancellation for
+// the limit to free the message. if dropLate is true, a response returned after the timeout is not sent,
+// as the client has probably retried already. a timeout of zero means
+// there is no limit, which is the default
+func (l *Server) SetHandlerTimeout(timeout time.Duration, dropLate bool) {
 	l.cbMutex.Lock()
-	l.msgCb = cb
+	l.timeout = timeout
+	l.dropLate = dropLate
 	l.cbMutex.Unlock()
 }
 
+// set the order in which options are written in responses
+func (l *Server) SetOptionOrder(order OptionOrder) {
+	l.cbMutex.Lock()
+	l.order = order
+	l.cbMutex.Unlock()
+}
+
+// set the limits applied when parsing incoming messages, which
+// are DefaultParseLimits unless this is called
+func (l *Server) SetParseLimits(limits ParseLimits) {
+	l.cbMutex.Lock()
+	l.limits = limits
+	l.cbMutex.Unlock()
+}
+
+// set the checks made on the source address of incoming messages,
+// which are all disabled unless this is called
+func (l *Server) SetSourceCheck(c SourceCheck) {
+	l.cbMutex.Lock()
+	l.sources = c
+	l.cbMutex.Unlock()
+}
+
+// set the access policies applied to incoming messages, replacing
+// any set before. see Policy for how they are applied
+func (l *Server) SetPolicies(policies []Policy) {
+	l.cbMutex.Lock()
+	l.policies = policies
+	l.cbMutex.Unlock()
+}
+
+// set what is done with messages which match none of the policies
+// set with SetPolicies. the default is DenyUnmatched
+func (l *Server) SetUnmatchedPolicy(d PolicyDefault) {
+	l.cbMutex.Lock()
+	l.unmatch = d
+	l.cbMutex.Unlock()
+}
+
+// set the policy applied to the responses to network boot clients,
+// after the handler has built them. a nil policy stops this. an error
+// is returned, and the policy is not set, if it is not valid
+func (l *Server) SetBootPolicy(p BootPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	l.cbMutex.Lock()
+	l.boot = p
+	l.cbMutex.Unlock()
+	return nil
+}
+
+// send the lease grants, NAKs, conflicts and errors of the Server
+// to s as well as its logger. a nil s stops this
+func (l *Server) SetSyslog(s *Syslog) {
+	l.cbMutex.Lock()
+	l.syslog = s
+	l.cbMutex.Unlock()
+}
+
+// set the server identifier (option 54) of every response, replacing
+// any set by the handler, so that servers sharing an anycast or load
+// balancer address present the same identity to clients. when the
+// Server listens on the wildcard address of a platform which supports
+// it, responses are also sent from this address. otherwise the Server
+// should be bound to it. a nil ip stops the override
+func (l *Server) SetServerIdentifier(ip net.IP) error {
+	var id net.IP
+	if ip != nil {
+		if err := setIPv4(&id, ip); err != nil {
+			return errors.Wrap(err, "set server identifier")
+		}
+	}
+
+	l.cbMutex.Lock()
+	l.serverID = id
+	l.cbMutex.Unlock()
+	return nil
+}
+
+// set the programs run when leases are committed, released or
+// expire. an empty Hooks runs nothing, which is the default
+func (l *Server) SetHooks(h Hooks) {
+	r := newHookRunner(h, l.log, &l.running)
+	l.cbMutex.Lock()
+	l.hooks = r
+	l.cbMutex.Unlock()
+}
+
+// run the expiry program of the Hooks for the lease of ip to hw. the
+// Server keeps no leases, so a handler with a lease store calls this
+// when one of its leases expires. the program runs in the background
+func (l *Server) RunExpiry(ip net.IP, hw net.HardwareAddr) {
+	l.cbMutex.RLock()
+	hooks := l.hooks
+	l.cbMutex.RUnlock()
+	if hooks != nil {
+		hooks.expiry(ip, hw)
+	}
+}
+
+// report an event to the syslog, if there is one
+func (l *Server) event(sev Severity, msgid, format string, v ...interface{}) {
+	l.cbMutex.RLock()
+	s := l.syslog
+	l.cbMutex.RUnlock()
+	if s == nil {
+		return
+	}
+
+	err := s.Log(sev, msgid, fmt.Sprintf(format, v...))
+	if err != nil {
+		l.log.Printf("error writing to syslog: %s", err)
+	}
+}
+
 func (l *Server) loop() {
-	buf := make([]byte, 4096)
+	defer close(l.done)
 	for {
-		select {
-		case <-l.ctx.Done():
-			return
-		default:
-			// time out often so we go round the loop and check ctx
-			l.socket.SetReadDeadline(time.Now().Add(time.Second))
-
-			// try to read a packet
-			n, addr, err := l.socket.ReadFromUDP(buf)
-			if err != nil {
-				if e, ok := err.(net.Error); ok && e.Timeout() {
-					continue // just a timeout
-				}
-				panic("can't read")
+		// try to read a packet. the buffer is owned by
+		// handleMsg, which returns it to the pool when done
+		buf := readPool.Get().(*[]byte)
+		var n int
+		var addr net.Addr
+		var info *packetInfo
+		var err error
+		if l.pktinfo {
+			n, addr, info, err = readWithInfo(l.socket, *buf)
+		} else {
+			n, addr, err = l.socket.ReadFrom(*buf)
+		}
+		if err != nil {
+			readPool.Put(buf)
+			if errors.Is(err, net.ErrClosed) || l.ctx.Err() != nil {
+				return // socket closed or server stopped
 			}
+			l.log.Printf("error reading from socket: %s", err)
+			continue
+		}
 
-			go l.handleMsg(buf[:n], addr)
-			if err != nil {
-				continue
-			}
+		from, ok := addr.(*net.UDPAddr)
+		if !ok {
+			readPool.Put(buf)
+			l.log.Printf("ignoring message from non-UDP address %s", addr)
+			continue
 		}
+
+		go l.handleMsg(buf, n, from, info)
+	}
+}
+
+// wake up the loop if the parent context of the Server
+// is cancelled without Stop being called
+func (l *Server) watch() {
+	select {
+	case <-l.ctx.Done():
+		l.socket.SetReadDeadline(time.Now())
+	case <-l.done:
 	}
 }
 
 // process an incoming DHCP message, dispatch it
-// to the right callback and send a response (if needed)
-func (l *Server) handleMsg(data []byte, from *net.UDPAddr) {
-	req, err := ParseMsg(data)
+// to the right callback and send a response (if needed).
+// the parsed request refers to buf, so both are only
+// returned to their pools once the callback has finished.
+// info is where the message arrived, if that is known
+func (l *Server) handleMsg(buf *[]byte, n int, from *net.UDPAddr, info *packetInfo) {
+	defer readPool.Put(buf)
+
+	req := acquireMsg()
+	defer ReleaseMsg(req)
+
+	l.cbMutex.RLock()
+	limits, sources, policies, unmatch := l.limits, l.sources, l.policies, l.unmatch
+	l.cbMutex.RUnlock()
+
+	var err error
+	data := (*buf)[:n]
+	var wrap *Msg4o6
+	if l.over6 {
+		wrap, data, err = parse4o6(data)
+		if err != nil {
+			l.log.Printf("error handling message from %s: %s", from, err)
+			return
+		}
+	}
+
+	err = parseMsgInto(req, data, limits)
 	if err != nil {
 		l.log.Printf("error handling message from %s: %s", from, err)
+		l.event(SevError, "ERROR", "bad message from %s: %s", from, err)
 		return
 	}
 
-	var res *Msg
-	l.cbMutex.RLock()
-	if l.msgCb != nil {
-		res = l.msgCb(*req)
+	// the source of a message over DHCPv6 is an IPv6 address,
+	// which says nothing about the DHCPv4 fields
+	if wrap == nil {
+		err = sources.check(req, from.IP)
+	}
+	if err != nil {
+		atomic.AddUint64(&l.stats.Spoofed, 1)
+		l.log.Printf("dropping message from %s: %s", from, err)
+		return
+	}
+
+	local, ifindex := l.address, 0
+	if info != nil {
+		local, ifindex = info.local, info.ifindex
+	}
+	err = checkPolicies(policies, unmatch, req, local, ifindex)
+	if err != nil {
+		atomic.AddUint64(&l.stats.Denied, 1)
+		l.log.Printf("denying message from %s: %s", from, err)
+		return
 	}
+
+	l.cbMutex.RLock()
+	handler, order, boot, serverID := l.msgCb, l.order, l.boot, l.serverID
+	hooks := l.hooks
+	timeout, dropLate := l.timeout, l.dropLate
 	l.cbMutex.RUnlock()
 
+	switch t, _ := req.DHCPMessageType(); t {
+	case Decline:
+		ip, _ := req.RequestedIPAddress()
+		l.event(SevWarning, "CONFLICT", "%s declined %s", req.Chaddr, ip)
+	case Release:
+		if hooks != nil {
+			hooks.release(req)
+		}
+	}
+
+	if handler == nil {
+		return
+	}
+	res, late := l.runHandler(handler, timeout, req, info)
+	if late {
+		atomic.AddUint64(&l.stats.Timeouts, 1)
+		l.log.Printf("handler timed out on message from %s", from)
+		if dropLate {
+			return
+		}
+	}
+
 	if res == nil {
 		return // no response, so we are done
 	}
+	applyBoot(boot, req, res)
+	if serverID != nil {
+		res.SetServerID(serverID)
+	}
+
+	b := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(b)
+	b.Reset()
 
-	payload := res.MarshalBytes()
-	_, err = l.socket.WriteToUDP(payload, from)
+	var first []OptionCode
+	if order == OrderRequested {
+		prl, _ := req.ParameterRequestList()
+		first = RequestedOrder(prl)
+	}
+
+	payload, err := res.marshalTo(b, first, replyLimit(req))
+	if err != nil {
+		l.log.Printf("error marshalling response to %s: %s", from, err)
+		l.event(SevError, "ERROR", "cannot marshal response to %s: %s", from, err)
+		return
+	}
+	to := replyAddr(req, res, from)
+	if wrap != nil {
+		payload = wrap.Reply(res).wrap(payload)
+		to = from
+	}
+	if info != nil {
+		_, err = writeWithInfo(l.socket, payload, to, info.source(res))
+	} else {
+		_, err = l.socket.WriteTo(payload, to)
+	}
 	if err != nil {
-		l.log.Printf("error writing response to %s: %s", from, err)
+		l.log.Printf("error writing response to %s: %s", to, err)
+		l.event(SevError, "ERROR", "cannot send response to %s: %s", to, err)
 		return
 	}
-	l.log.Printf("sent response to %s", from)
+	l.log.Printf("sent response to %s", to)
+
+	switch t, _ := res.DHCPMessageType(); t {
+	case ACK:
+		l.event(SevInfo, "GRANT", "%s acked to %s", res.Yiaddr, res.Chaddr)
+		if hooks != nil {
+			hooks.commit(req, res)
+		}
+	case NAK:
+		l.event(SevNotice, "NAK", "nak sent to %s", res.Chaddr)
+	}
+}
+
+// call handler with req, giving it a context which is cancelled after
+// timeout (if it is not zero). the returned flag is true if the handler
+// did not return in time. the handler is always waited for, as req and
+// its buffer are only recycled once it has returned
+func (l *Server) runHandler(handler MsgHandler, timeout time.Duration, req *Msg, info *packetInfo) (*Msg, bool) {
+	ctx := l.ctx
+	if info != nil && info.ifindex > 0 {
+		ctx = context.WithValue(ctx, ifindexKey{}, info.ifindex)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res := handler(ctx, *req)
+	return res, ctx.Err() == context.DeadlineExceeded
+}
+
+// apply the boot policy p to the response res to req. only an offer
+// or an ack carries boot parameters, a NAK must not have the file or
+// siaddr fields or the options of p (RFC2131 table 3)
+func applyBoot(p BootPolicy, req, res *Msg) {
+	if p == nil {
+		return
+	}
+	switch t, _ := res.DHCPMessageType(); t {
+	case Offer, ACK:
+		p.Apply(req, res)
+	}
+}
+
+// choose where to send the response res to the request req, which
+// was received from the address from, following RFC2131 chapter 4.1.
+// responses to a relay go to its server port, and responses to a
+// client that does not have an address yet are broadcast, as are
+// all NAKs which are not relayed
+func replyAddr(req, res *Msg, from *net.UDPAddr) *net.UDPAddr {
+	if req.Giaddr != nil && !req.Giaddr.IsUnspecified() {
+		return &net.UDPAddr{IP: req.Giaddr, Port: ServerPort}
+	}
+
+	t, _ := res.DHCPMessageType()
+	if from.IP.IsUnspecified() || t == NAK {
+		return &net.UDPAddr{IP: net.IPv4bcast, Port: from.Port}
+	}
+	return from
+}
+
+// get the largest response that the sender of req can accept,
+// based on the maximum message size option if it is present
+func replyLimit(req *Msg) int {
+	limit := DefaultMaxMessageSize
+	if size, err := req.MaxMessageSize(); err == nil && int(size) > limit {
+		limit = int(size)
+	}
+	return limit - ipUDPHeaderLen
 }
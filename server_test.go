@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -18,7 +19,7 @@ var (
 )
 
 func TestServerE2E(t *testing.T) {
-	serv := NewServer(context.Background(), testLogg, testAddr, testPort)
+	serv := NewServer(WithLogger(testLogg), WithAddress(testAddr), WithPort(testPort))
 
 	err := serv.Start()
 	if err != nil {
@@ -47,13 +48,18 @@ func TestServerE2E(t *testing.T) {
 	})
 
 	conn, err := net.DialUDP("udp4", nil,
-		&net.UDPAddr{testAddr, testPort, ""})
+		&net.UDPAddr{IP: testAddr, Port: testPort})
 	if err != nil {
 		t.Fatalf("can't dial test host: %s", err)
 	}
 	defer conn.Close()
 
-	_, err = conn.Write(msg.MarshalBytes())
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+
+	_, err = conn.Write(payload)
 	if err != nil {
 		t.Fatalf("cannot write message to socket: %s", err)
 	}
@@ -72,3 +78,282 @@ func TestServerE2E(t *testing.T) {
 		t.Fatalf("could not stop server: %s", err)
 	}
 }
+
+func TestServerContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	serv := NewServer(WithContext(ctx), WithLogger(testLogg), WithAddress(testAddr), WithPort(testPort))
+
+	err := serv.Start()
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+
+	cancel()
+	select {
+	case <-serv.done:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("timed out waiting for loop to exit")
+	}
+
+	err = serv.Stop()
+	if err != nil {
+		t.Fatalf("could not stop server: %s", err)
+	}
+}
+
+var replyAddrCases = []struct {
+	giaddr  net.IP
+	msgType MessageType
+	from    *net.UDPAddr
+	to      *net.UDPAddr
+}{
+	// 0 configured client is answered directly
+	{net.IPv4zero, ACK,
+		&net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: ClientPort},
+		&net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: ClientPort}},
+	// 1 unconfigured client gets a broadcast
+	{net.IPv4zero, Offer,
+		&net.UDPAddr{IP: net.IPv4zero, Port: ClientPort},
+		&net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}},
+	// 2 naks are always broadcast
+	{net.IPv4zero, NAK,
+		&net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: ClientPort},
+		&net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}},
+	// 3 relayed messages go back to the relay
+	{net.IPv4(10, 0, 0, 1), NAK,
+		&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: ServerPort},
+		&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: ServerPort}},
+}
+
+func TestReplyAddr(t *testing.T) {
+	for i, tc := range replyAddrCases {
+		req := NewMsg()
+		req.Giaddr = tc.giaddr
+		res := NewMsg()
+		res.Options[OptionDHCPMessageType] = []byte{byte(tc.msgType)}
+
+		got := replyAddr(req, res, tc.from)
+		if !tc.to.IP.Equal(got.IP) || tc.to.Port != got.Port {
+			t.Errorf("case %d expected %s got %s", i, tc.to, got)
+		}
+	}
+}
+
+func TestServerStartConn(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+
+	serv := NewServer(WithLogger(testLogg))
+	serv.RegisterCallback(func(req Msg) *Msg {
+		res := NewMsg()
+		res.Op = 2
+		res.Hlen = req.Hlen
+		res.Xid = req.Xid
+		res.Options[OptionDHCPMessageType] = []byte{byte(Offer)}
+		return res
+	})
+
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("can't dial test host: %s", err)
+	}
+	defer client.Close()
+
+	msg := NewMsg()
+	msg.Hlen = 6
+	msg.Xid = 0x12345678
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	_, err = client.Write(payload)
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("did not receive a reply: %s", err)
+	}
+	res, err := ParseMsg(buf[:n])
+	if err != nil {
+		t.Fatalf("could not parse reply: %s", err)
+	}
+	if res.Xid != msg.Xid {
+		t.Errorf("reply has wrong xid, expected %x got %x", msg.Xid, res.Xid)
+	}
+
+	err = serv.Stop()
+	if err != nil {
+		t.Fatalf("could not stop server: %s", err)
+	}
+}
+
+func TestServerHandlerTimeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+
+	serv := NewServer(WithLogger(testLogg))
+	serv.SetHandlerTimeout(20*time.Millisecond, true)
+	serv.RegisterHandler(func(ctx context.Context, req Msg) *Msg {
+		<-ctx.Done() // a backend which never answers
+		res := NewMsg()
+		res.Xid = req.Xid
+		return res
+	})
+
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("can't dial test host: %s", err)
+	}
+	defer client.Close()
+
+	msg := NewMsg()
+	msg.Hlen = 6
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	_, err = client.Write(payload)
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err = client.Read(buf)
+	if err == nil {
+		t.Error("expected late response to be dropped")
+	}
+	if got := serv.Stats().Timeouts; got != 1 {
+		t.Errorf("expected 1 timeout, got %d", got)
+	}
+
+	err = serv.Stop()
+	if err != nil {
+		t.Fatalf("could not stop server: %s", err)
+	}
+}
+
+func TestApplyBoot(t *testing.T) {
+	p := BootPolicy{ArchX86BIOS: {File: "pxelinux.0", NextServer: net.IPv4(10, 0, 0, 5)}}
+	req := NewMsg()
+	req.Options[OptionVendorClassIdentifier] = []byte("PXEClient:Arch:00000:UNDI:002001")
+	req.Options[OptionClientSystemArchitecture] = []byte{0, 0}
+
+	for _, tc := range []struct {
+		t     MessageType
+		apply bool
+	}{
+		{Offer, true},
+		{ACK, true},
+		{NAK, false},
+	} {
+		res := NewMsg()
+		res.Options[OptionDHCPMessageType] = []byte{byte(tc.t)}
+		applyBoot(p, req, res)
+
+		_, class := res.Options[OptionVendorClassIdentifier]
+		applied := res.File != "" || !res.Siaddr.IsUnspecified() || class
+		if applied != tc.apply {
+			t.Errorf("%s: expected boot policy applied %t, got file %q siaddr %s",
+				messageTypeNames[tc.t], tc.apply, res.File, res.Siaddr)
+		}
+	}
+}
+
+func TestNewServerDefaults(t *testing.T) {
+	serv := NewServer(WithLogger(nil))
+	if serv.port != ServerPort || !serv.address.Equal(net.IPv4zero) {
+		t.Errorf("expected default of 0.0.0.0:%d got %s:%d", ServerPort, serv.address, serv.port)
+	}
+	if serv.ctx.Err() != nil {
+		t.Errorf("expected context to be live")
+	}
+
+	// a nil logger is replaced by one which discards, so this does not panic
+	serv.log.Print("no logger")
+	serv.Stop()
+}
+
+func TestServerIdentifierOverride(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+
+	anycast := net.IPv4(127, 0, 0, 9)
+	serv := NewServer(WithLogger(testLogg))
+	if err := serv.SetServerIdentifier(net.ParseIP("2001:db8::1")); err == nil {
+		t.Errorf("expected error for an IPv6 server identifier")
+	}
+	if err := serv.SetServerIdentifier(anycast); err != nil {
+		t.Fatalf("could not set server identifier: %s", err)
+	}
+	serv.RegisterHandler(func(_ context.Context, req Msg) *Msg {
+		res := NewMsg()
+		res.Op = 2
+		res.Hlen = 6
+		res.Xid = req.Xid
+		res.Options[OptionDHCPMessageType] = []byte{byte(ACK)}
+		res.Options[OptionServerIdentifier] = []byte{10, 0, 0, 1}
+		return res
+	})
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+	defer serv.Stop()
+
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("could not open client socket: %s", err)
+	}
+	defer client.Close()
+
+	msg := NewMsg()
+	msg.Hlen = 6
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	_, err = client.WriteTo(payload, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, from, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no response: %s", err)
+	}
+	res, err := ParseMsg(buf[:n])
+	if err != nil {
+		t.Fatalf("cannot parse response: %s", err)
+	}
+	if id := net.IP(res.Options[OptionServerIdentifier]); !id.Equal(anycast) {
+		t.Errorf("expected server identifier %s got %s", anycast, id)
+	}
+	// the source follows the identifier where IP_PKTINFO is used
+	if ip := from.(*net.UDPAddr).IP; runtime.GOOS == "linux" && !ip.Equal(anycast) {
+		t.Errorf("expected response from %s got %s", anycast, ip)
+	}
+}
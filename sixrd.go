@@ -0,0 +1,95 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// SixRD holds the parameters of a 6rd domain, which tell a CPE how
+// to build its IPv6 prefix from its IPv4 address (RFC5969 chapter 7.1)
+type SixRD struct {
+	IPv4MaskLen  int      // high bits of the IPv4 address common to the domain
+	PrefixLen    int      // length of Prefix in bits
+	Prefix       net.IP   // the 6rd IPv6 prefix
+	BorderRelays []net.IP // IPv4 addresses of the border relays
+}
+
+// option 212
+func (o Options) SixRD() (*SixRD, error) {
+	v, ok := o[Option6RD]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) < 22 {
+		return nil, &OptionError{Option6RD, ErrShortRead}
+	}
+	if (len(v)-18)%4 != 0 {
+		return nil, &OptionError{Option6RD, errors.Errorf("invalid length %d", len(v))}
+	}
+
+	s := &SixRD{
+		IPv4MaskLen: int(v[0]),
+		PrefixLen:   int(v[1]),
+		Prefix:      net.IP(v[2:18]),
+	}
+	for i := 18; i < len(v); i += 4 {
+		s.BorderRelays = append(s.BorderRelays, net.IP(v[i:i+4]))
+	}
+	err := s.validate()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// set option 212
+func (o Options) SetSixRD(s *SixRD) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+
+	v := make([]byte, 18, 18+4*len(s.BorderRelays))
+	v[0] = byte(s.IPv4MaskLen)
+	v[1] = byte(s.PrefixLen)
+	copy(v[2:18], s.Prefix.To16())
+	for _, br := range s.BorderRelays {
+		v = append(v, br.To4()...)
+	}
+	o[Option6RD] = v
+	return nil
+}
+
+// check the parameters make sense, including that the delegated
+// prefix is not longer than an IPv6 address
+func (s *SixRD) validate() error {
+	err := s.check()
+	if err != nil {
+		return &OptionError{Option6RD, err}
+	}
+	return nil
+}
+
+func (s *SixRD) check() error {
+	if s.IPv4MaskLen < 0 || s.IPv4MaskLen > 32 {
+		return errors.Errorf("invalid 6rd IPv4 mask length %d", s.IPv4MaskLen)
+	}
+	if s.PrefixLen < 0 || s.PrefixLen+32-s.IPv4MaskLen > 128 {
+		return errors.Errorf("invalid 6rd prefix length %d", s.PrefixLen)
+	}
+	if s.Prefix.To16() == nil || s.Prefix.To4() != nil {
+		return errors.Errorf("6rd prefix %s is not an IPv6 address", s.Prefix)
+	}
+	if len(s.BorderRelays) == 0 {
+		return errors.New("6rd option needs at least one border relay")
+	}
+	if n := 18 + 4*len(s.BorderRelays); n > 255 {
+		return errors.Errorf("%d border relays need %d bytes, more than 255", len(s.BorderRelays), n)
+	}
+	for _, br := range s.BorderRelays {
+		if br.To4() == nil {
+			return errors.Wrapf(ErrNotIPv4, "%s", br)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package jdhcp
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"net"
+	"testing"
+)
+
+func TestSixRD(t *testing.T) {
+	o := make(Options)
+	s1 := &SixRD{
+		IPv4MaskLen:  8,
+		PrefixLen:    32,
+		Prefix:       net.ParseIP("2001:db8::"),
+		BorderRelays: []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.IPv4(192, 0, 2, 2).To4()},
+	}
+	err := o.SetSixRD(s1)
+	if err != nil {
+		t.Fatalf("o.SetSixRD() returned error: %s", err)
+	}
+
+	expected := []byte{8, 32,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		192, 0, 2, 1, 192, 0, 2, 2}
+	if diff := cmp.Diff(expected, o[Option6RD]); diff != "" {
+		t.Errorf("wrong encoding: %s", diff)
+	}
+
+	s2, err := o.SixRD()
+	if err != nil {
+		t.Fatalf("o.SixRD() returned error: %s", err)
+	}
+	if diff := cmp.Diff(s1, s2); diff != "" {
+		t.Errorf("returned incorrect 6rd parameters: %s", diff)
+	}
+}
+
+func TestSixRDInvalid(t *testing.T) {
+	for i, s := range []*SixRD{
+		{IPv4MaskLen: 33, PrefixLen: 32, Prefix: net.ParseIP("2001:db8::"),
+			BorderRelays: []net.IP{net.IPv4(192, 0, 2, 1)}},
+		{IPv4MaskLen: 0, PrefixLen: 112, Prefix: net.ParseIP("2001:db8::"),
+			BorderRelays: []net.IP{net.IPv4(192, 0, 2, 1)}},
+		{IPv4MaskLen: 8, PrefixLen: 32, Prefix: net.IPv4(192, 0, 2, 1),
+			BorderRelays: []net.IP{net.IPv4(192, 0, 2, 1)}},
+		{IPv4MaskLen: 8, PrefixLen: 32, Prefix: net.ParseIP("2001:db8::")},
+	} {
+		err := make(Options).SetSixRD(s)
+		if err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+
+	relays := make([]net.IP, 60)
+	for i := range relays {
+		relays[i] = net.IPv4(192, 0, 2, byte(i))
+	}
+	o := make(Options)
+	err := o.SetSixRD(&SixRD{IPv4MaskLen: 8, PrefixLen: 32,
+		Prefix: net.ParseIP("2001:db8::"), BorderRelays: relays})
+	if _, ok := err.(*OptionError); !ok {
+		t.Errorf("expected an OptionError for 60 border relays, got %v", err)
+	}
+	if _, ok := o[Option6RD]; ok {
+		t.Errorf("option set despite error")
+	}
+
+	o = Options{Option6RD: make([]byte, 23)}
+	_, err = o.SixRD()
+	if err == nil {
+		t.Error("expected an error for a bad length")
+	}
+}
@@ -0,0 +1,46 @@
+package jdhcp
+
+import (
+	"github.com/pkg/errors"
+	"net"
+)
+
+// SourceCheck describes the checks a Server makes on the source
+// address of each message before it reaches the handler, so that
+// spoofed traffic can be dropped. the zero value makes no checks
+type SourceCheck struct {
+	// require the source of a unicast message to be the giaddr of
+	// a relayed message, or the ciaddr of a client which has one
+	Consistent bool
+
+	// if not empty, relayed messages must come from one of these
+	Relays []net.IP
+}
+
+// check that a message req received from src passes the checks
+func (c SourceCheck) check(req *Msg, src net.IP) error {
+	relayed := req.Giaddr != nil && !req.Giaddr.IsUnspecified()
+
+	if c.Consistent && !src.IsUnspecified() {
+		if relayed && !src.Equal(req.Giaddr) {
+			return errors.Errorf("source %s does not match giaddr %s", src, req.Giaddr)
+		}
+		if !relayed && req.Ciaddr != nil && !req.Ciaddr.IsUnspecified() &&
+			!src.Equal(req.Ciaddr) {
+			return errors.Errorf("source %s does not match ciaddr %s", src, req.Ciaddr)
+		}
+	}
+	if c.Consistent && relayed && src.IsUnspecified() {
+		return errors.New("relayed message has no source address")
+	}
+
+	if relayed && len(c.Relays) > 0 {
+		for _, r := range c.Relays {
+			if src.Equal(r) {
+				return nil
+			}
+		}
+		return errors.Errorf("relayed message from unknown relay %s", src)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package jdhcp
+
+import (
+	"net"
+	"testing"
+)
+
+var (
+	testRelay  = net.IPv4(10, 0, 0, 1)
+	testClient = net.IPv4(192, 168, 1, 10)
+)
+
+var sourceCheckCases = []struct {
+	check  SourceCheck
+	ciaddr net.IP
+	giaddr net.IP
+	src    net.IP
+	ok     bool
+}{
+	// 0 no checks
+	{SourceCheck{}, testClient, net.IPv4zero, testRelay, true},
+	// 1 unconfigured client
+	{SourceCheck{Consistent: true}, net.IPv4zero, net.IPv4zero, net.IPv4zero, true},
+	// 2 renewing client
+	{SourceCheck{Consistent: true}, testClient, net.IPv4zero, testClient, true},
+	// 3 renewal from the wrong address
+	{SourceCheck{Consistent: true}, testClient, net.IPv4zero, testRelay, false},
+	// 4 relayed message
+	{SourceCheck{Consistent: true}, testClient, testRelay, testRelay, true},
+	// 5 relayed message from somewhere else
+	{SourceCheck{Consistent: true}, net.IPv4zero, testRelay, testClient, false},
+	// 6 relayed message without a source
+	{SourceCheck{Consistent: true}, net.IPv4zero, testRelay, net.IPv4zero, false},
+	// 7 known relay
+	{SourceCheck{Relays: []net.IP{testRelay}}, net.IPv4zero, testRelay, testRelay, true},
+	// 8 unknown relay
+	{SourceCheck{Relays: []net.IP{testRelay}}, net.IPv4zero, testClient, testClient, false},
+	// 9 relays are not checked for direct messages
+	{SourceCheck{Relays: []net.IP{testRelay}}, testClient, net.IPv4zero, testClient, true},
+}
+
+func TestSourceCheck(t *testing.T) {
+	for i, tc := range sourceCheckCases {
+		req := NewMsg()
+		req.Ciaddr = tc.ciaddr
+		req.Giaddr = tc.giaddr
+
+		err := tc.check.check(req, tc.src)
+		if tc.ok && err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package jdhcp
+
+import (
+	"sync/atomic"
+)
+
+// Stats counts the messages which a Server has dealt with
+// itself, without the result being visible to the handler
+type Stats struct {
+	Timeouts uint64 // handlers which did not return before the timeout
+	Spoofed  uint64 // messages dropped by the SourceCheck
+	Denied   uint64 // messages dropped by a Policy
+}
+
+// get a snapshot of the Stats of the Server
+func (l *Server) Stats() Stats {
+	return Stats{
+		Timeouts: atomic.LoadUint64(&l.stats.Timeouts),
+		Spoofed:  atomic.LoadUint64(&l.stats.Spoofed),
+		Denied:   atomic.LoadUint64(&l.stats.Denied),
+	}
+}
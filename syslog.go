@@ -0,0 +1,95 @@
+package jdhcp
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the severity of a syslog message (RFC5424 chapter 6.2.1)
+type Severity int
+
+const (
+	SevError   Severity = 3
+	SevWarning Severity = 4
+	SevNotice  Severity = 5
+	SevInfo    Severity = 6
+)
+
+// the daemon facility, used for all messages
+const syslogFacility = 3
+
+// a Syslog sends RFC5424 formatted messages to a syslog server over
+// UDP, TCP or a unix socket, so that the lease grants, NAKs, conflicts
+// and errors of a Server can be collected with other network logs.
+// it is safe for concurrent use
+type Syslog struct {
+	network  string
+	app      string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// connect to a syslog server. network is "udp", "tcp", "unix" or
+// "unixgram", and app is the name the messages are sent under
+func DialSyslog(network, address, app string) (*Syslog, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to syslog")
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &Syslog{
+		network:  network,
+		app:      app,
+		hostname: hostname,
+		conn:     conn,
+	}, nil
+}
+
+// send a message with the given severity. msgid identifies the kind
+// of event, such as "GRANT", and should not contain spaces
+func (s *Syslog) Log(sev Severity, msgid, msg string) error {
+	if msgid == "" {
+		msgid = "-"
+	}
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		syslogFacility*8+int(sev),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		s.hostname, s.app, os.Getpid(), msgid,
+		strings.TrimRight(msg, "\n"))
+
+	// stream transports need each message to be framed
+	// with its length (RFC6587 chapter 3.4.1)
+	if s.network == "tcp" || s.network == "unix" {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+// send p as an informational message, so that a Syslog can be used
+// as the output of a log.Logger
+func (s *Syslog) Write(p []byte) (int, error) {
+	err := s.Log(SevInfo, "", string(p))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// close the connection to the syslog server
+func (s *Syslog) Close() error {
+	return s.conn.Close()
+}
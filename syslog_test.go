@@ -0,0 +1,98 @@
+package jdhcp
+
+import (
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSyslog(t *testing.T) {
+	recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	defer recv.Close()
+
+	s, err := DialSyslog("udp", recv.LocalAddr().String(), "jdhcp")
+	if err != nil {
+		t.Fatalf("could not dial syslog: %s", err)
+	}
+	defer s.Close()
+
+	err = s.Log(SevWarning, "CONFLICT", "something happened\n")
+	if err != nil {
+		t.Fatalf("could not log: %s", err)
+	}
+
+	buf := make([]byte, 1024)
+	recv.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := recv.Read(buf)
+	if err != nil {
+		t.Fatalf("did not receive a message: %s", err)
+	}
+	format := regexp.MustCompile(`^<28>1 \S+ \S+ jdhcp \d+ CONFLICT - something happened$`)
+	if !format.Match(buf[:n]) {
+		t.Errorf("message has the wrong format: %q", buf[:n])
+	}
+}
+
+func TestServerSyslog(t *testing.T) {
+	recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	defer recv.Close()
+	s, err := DialSyslog("udp", recv.LocalAddr().String(), "jdhcp")
+	if err != nil {
+		t.Fatalf("could not dial syslog: %s", err)
+	}
+	defer s.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: testAddr})
+	if err != nil {
+		t.Fatalf("could not open socket: %s", err)
+	}
+	serv := NewServer(WithLogger(testLogg))
+	serv.SetSyslog(s)
+	serv.RegisterCallback(func(req Msg) *Msg {
+		res := NewMsg()
+		res.Op = 2
+		res.Hlen = req.Hlen
+		res.Yiaddr = net.IPv4(10, 0, 0, 5)
+		res.Options[OptionDHCPMessageType] = []byte{byte(ACK)}
+		return res
+	})
+	err = serv.StartConn(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+	defer serv.Stop()
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("can't dial test host: %s", err)
+	}
+	defer client.Close()
+	msg := NewMsg()
+	msg.Hlen = 6
+	payload, err := msg.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	_, err = client.Write(payload)
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1024)
+	recv.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := recv.Read(buf)
+	if err != nil {
+		t.Fatalf("did not receive a message: %s", err)
+	}
+	format := regexp.MustCompile(`^<30>1 .* GRANT - 10\.0\.0\.5 acked to 00:00:00:00:00:00$`)
+	if !format.Match(buf[:n]) {
+		t.Errorf("message has the wrong format: %q", buf[:n])
+	}
+}
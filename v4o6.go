@@ -0,0 +1,185 @@
+package jdhcp
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"net"
+)
+
+// DHCPv6 message types and options used to carry DHCPv4 messages
+// over DHCPv6 (RFC7341), and to pass them through DHCPv6 relays
+const (
+	dhcp6RelayForw       = 12
+	dhcp6RelayRepl       = 13
+	dhcp6DHCPv4Query     = 20
+	dhcp6DHCPv4Response  = 21
+	opt6RelayMsg         = 9
+	opt6InterfaceID      = 18
+	opt6DHCPv4Msg        = 87
+	dhcp6HopCountLimit   = 32
+	dhcp6RelayHeaderLen  = 34
+	dhcp6OptionHeaderLen = 4
+)
+
+// set in the flags of a DHCPV4-QUERY which the client sent by unicast
+const Flag4o6Unicast = 0x800000
+
+// a Msg4o6 is a DHCPv4 message carried inside a DHCPv6 DHCPV4-QUERY
+// or DHCPV4-RESPONSE message, as defined in RFC7341. this lets a
+// Server serve DHCPv4 clients over a network which only has IPv6
+type Msg4o6 struct {
+	Response bool   // DHCPV4-RESPONSE rather than DHCPV4-QUERY
+	Flags    uint32 // the 24-bit flags field
+	Msg      *Msg
+
+	// the DHCPv6 relays the message passed through, outermost first
+	Relays []Relay6
+}
+
+// the parts of a DHCPv6 RELAY-FORW message that are needed to
+// send the response back through the same relay
+type Relay6 struct {
+	HopCount    byte
+	LinkAddr    net.IP
+	PeerAddr    net.IP
+	InterfaceID []byte // nil if the relay did not send one
+}
+
+// parse a DHCPv6 message containing a DHCPv4 message, which may
+// be wrapped in any number of RELAY-FORW or RELAY-REPL messages
+func ParseMsg4o6(data []byte) (*Msg4o6, error) {
+	m, v4, err := parse4o6(data)
+	if err != nil {
+		return nil, err
+	}
+	m.Msg, err = ParseMsg(v4)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse DHCPv4 message")
+	}
+	return m, nil
+}
+
+// parse the DHCPv6 part of a Msg4o6, returning the bytes of the
+// DHCPv4 message inside it. the Msg field is left nil
+func parse4o6(data []byte) (*Msg4o6, []byte, error) {
+	m := &Msg4o6{}
+	for {
+		if len(data) < 4 {
+			return nil, nil, errors.Wrap(ErrShortRead, "DHCPv6 header")
+		}
+
+		switch data[0] {
+		case dhcp6RelayForw, dhcp6RelayRepl:
+			if len(m.Relays) >= dhcp6HopCountLimit {
+				return nil, nil, errors.New("too many DHCPv6 relays")
+			}
+			if len(data) < dhcp6RelayHeaderLen {
+				return nil, nil, errors.Wrap(ErrShortRead, "DHCPv6 relay header")
+			}
+			r := Relay6{
+				HopCount: data[1],
+				LinkAddr: net.IP(data[2:18]),
+				PeerAddr: net.IP(data[18:34]),
+			}
+			opts, err := parseOptions6(data[dhcp6RelayHeaderLen:])
+			if err != nil {
+				return nil, nil, err
+			}
+			r.InterfaceID = opts[opt6InterfaceID]
+			inner, ok := opts[opt6RelayMsg]
+			if !ok {
+				return nil, nil, errors.Wrap(ErrOptionNotPresent, "DHCPv6 relay message")
+			}
+			m.Relays = append(m.Relays, r)
+			data = inner
+
+		case dhcp6DHCPv4Query, dhcp6DHCPv4Response:
+			m.Response = data[0] == dhcp6DHCPv4Response
+			m.Flags = uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+			opts, err := parseOptions6(data[4:])
+			if err != nil {
+				return nil, nil, err
+			}
+			v4, ok := opts[opt6DHCPv4Msg]
+			if !ok {
+				return nil, nil, errors.Wrap(ErrOptionNotPresent, "DHCPv4 message option")
+			}
+			return m, v4, nil
+
+		default:
+			return nil, nil, errors.Errorf("unsupported DHCPv6 message type %d", data[0])
+		}
+	}
+}
+
+// parse DHCPv6 options, which have a 2-byte code and length.
+// the values refer to the contents of data
+func parseOptions6(data []byte) (map[uint16][]byte, error) {
+	opts := make(map[uint16][]byte)
+	for len(data) > 0 {
+		if len(data) < dhcp6OptionHeaderLen {
+			return nil, errors.Wrap(ErrShortRead, "DHCPv6 option header")
+		}
+		code := binary.BigEndian.Uint16(data[0:2])
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < dhcp6OptionHeaderLen+l {
+			return nil, errors.Wrapf(ErrShortRead, "DHCPv6 option %d", code)
+		}
+		opts[code] = data[dhcp6OptionHeaderLen : dhcp6OptionHeaderLen+l]
+		data = data[dhcp6OptionHeaderLen+l:]
+	}
+	return opts, nil
+}
+
+// convert a Msg4o6 to the network representation
+func (m *Msg4o6) MarshalBytes() ([]byte, error) {
+	v4, err := m.Msg.MarshalBytes()
+	if err != nil {
+		return nil, err
+	}
+	return m.wrap(v4), nil
+}
+
+// build the response to m which carries res, to be sent back
+// through the same relays
+func (m *Msg4o6) Reply(res *Msg) *Msg4o6 {
+	return &Msg4o6{
+		Response: true,
+		Msg:      res,
+		Relays:   m.Relays,
+	}
+}
+
+// wrap the bytes of a DHCPv4 message in the DHCPv6 headers of m. any
+// relays are wrapped around it as RELAY-REPL messages when m is a
+// response, and as RELAY-FORW messages otherwise
+func (m *Msg4o6) wrap(v4 []byte) []byte {
+	t, relayType := byte(dhcp6DHCPv4Query), byte(dhcp6RelayForw)
+	if m.Response {
+		t, relayType = dhcp6DHCPv4Response, dhcp6RelayRepl
+	}
+
+	b := []byte{t, byte(m.Flags >> 16), byte(m.Flags >> 8), byte(m.Flags)}
+	b = appendOption6(b, opt6DHCPv4Msg, v4)
+
+	for i := len(m.Relays) - 1; i >= 0; i-- {
+		r := m.Relays[i]
+		hdr := make([]byte, dhcp6RelayHeaderLen, dhcp6RelayHeaderLen+len(b)+16)
+		hdr[0] = relayType
+		hdr[1] = r.HopCount
+		copy(hdr[2:18], r.LinkAddr.To16())
+		copy(hdr[18:34], r.PeerAddr.To16())
+		if r.InterfaceID != nil {
+			hdr = appendOption6(hdr, opt6InterfaceID, r.InterfaceID)
+		}
+		b = appendOption6(hdr, opt6RelayMsg, b)
+	}
+	return b
+}
+
+func appendOption6(b []byte, code uint16, v []byte) []byte {
+	var hdr [dhcp6OptionHeaderLen]byte
+	binary.BigEndian.PutUint16(hdr[0:2], code)
+	binary.BigEndian.PutUint16(hdr[2:4], uint16(len(v)))
+	return append(append(b, hdr[:]...), v...)
+}
@@ -0,0 +1,119 @@
+package jdhcp
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMsg4o6RoundTrip(t *testing.T) {
+	for i, m := range []*Msg4o6{
+		{Flags: Flag4o6Unicast, Msg: messageParseCases[0].asStruct},
+		{Response: true, Msg: messageParseCases[1].asStruct},
+		{Msg: messageParseCases[0].asStruct, Relays: []Relay6{
+			{HopCount: 1, LinkAddr: net.ParseIP("2001:db8::1"),
+				PeerAddr: net.ParseIP("fe80::1"), InterfaceID: []byte("eth0")},
+			{HopCount: 0, LinkAddr: net.ParseIP("2001:db8:1::1"),
+				PeerAddr: net.ParseIP("fe80::2")},
+		}},
+	} {
+		b, err := m.MarshalBytes()
+		if err != nil {
+			t.Fatalf("case %d: cannot marshal: %s", i, err)
+		}
+		got, err := ParseMsg4o6(b)
+		if err != nil {
+			t.Fatalf("case %d: cannot parse: %s", i, err)
+		}
+		if diff := cmp.Diff(m, got); diff != "" {
+			t.Errorf("case %d: round trip changed message: %s", i, diff)
+		}
+	}
+}
+
+func TestParseMsg4o6Bytes(t *testing.T) {
+	v4 := messageParseCases[0].asBytes
+	data := append([]byte{
+		dhcp6DHCPv4Query, 0x80, 0x00, 0x00,
+		0x00, opt6DHCPv4Msg, byte(len(v4) >> 8), byte(len(v4)),
+	}, v4...)
+
+	m, err := ParseMsg4o6(data)
+	if err != nil {
+		t.Fatalf("cannot parse: %s", err)
+	}
+	if m.Response || m.Flags != Flag4o6Unicast {
+		t.Errorf("wrong header, got %+v", m)
+	}
+	if m.Msg.Xid != messageParseCases[0].asStruct.Xid {
+		t.Errorf("wrong DHCPv4 message, got xid %x", m.Msg.Xid)
+	}
+
+	for _, n := range []int{3, 7, len(data) - 1} {
+		_, err = ParseMsg4o6(data[:n])
+		if err == nil {
+			t.Errorf("expected an error when truncated to %d bytes", n)
+		}
+	}
+}
+
+func TestServer4o6(t *testing.T) {
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %s", err)
+	}
+
+	serv := NewServer(WithLogger(testLogg))
+	serv.RegisterCallback(func(req Msg) *Msg {
+		res := NewMsg()
+		res.Op = 2
+		res.Hlen = req.Hlen
+		res.Xid = req.Xid
+		return res
+	})
+	err = serv.StartConn4o6(conn)
+	if err != nil {
+		t.Fatalf("could not start server: %s", err)
+	}
+	defer serv.Stop()
+
+	client, err := net.DialUDP("udp6", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("can't dial test host: %s", err)
+	}
+	defer client.Close()
+
+	relay := Relay6{LinkAddr: net.ParseIP("2001:db8::1"),
+		PeerAddr: net.ParseIP("fe80::1"), InterfaceID: []byte("port1")}
+	req := &Msg4o6{Msg: messageParseCases[0].asStruct, Relays: []Relay6{relay}}
+	payload, err := req.MarshalBytes()
+	if err != nil {
+		t.Fatalf("cannot marshal message: %s", err)
+	}
+	_, err = client.Write(payload)
+	if err != nil {
+		t.Fatalf("cannot write message to socket: %s", err)
+	}
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("did not receive a reply: %s", err)
+	}
+	if buf[0] != dhcp6RelayRepl {
+		t.Errorf("expected a RELAY-REPL, got message type %d", buf[0])
+	}
+	res, err := ParseMsg4o6(buf[:n])
+	if err != nil {
+		t.Fatalf("could not parse reply: %s", err)
+	}
+	if !res.Response || res.Msg.Xid != req.Msg.Xid {
+		t.Errorf("wrong reply, got %+v", res)
+	}
+	if len(res.Relays) != 1 || !bytes.Equal(res.Relays[0].InterfaceID, relay.InterfaceID) {
+		t.Errorf("reply did not go back through the relay, got %+v", res.Relays)
+	}
+}
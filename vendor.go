@@ -0,0 +1,232 @@
+package jdhcp
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// VendorOptions are the sub-options encapsulated in option 43, as a
+// mapping from sub-option code to value. what the codes mean depends
+// on the vendor. a value can itself hold sub-options, as in PXE and
+// some CPE provisioning schemes, which are decoded with Sub
+type VendorOptions map[byte][]byte
+
+// parse a series of code, length and value sub-options, which may
+// be padded and ended with the same Pad and End codes as options
+func ParseVendorOptions(data []byte) (VendorOptions, error) {
+	v := make(VendorOptions)
+	for i := 0; i < len(data); {
+		code := data[i]
+		if code == byte(OptionPad) {
+			i++
+			continue
+		}
+		if code == byte(OptionEnd) {
+			break
+		}
+		if i+1 >= len(data) || i+2+int(data[i+1]) > len(data) {
+			return nil, errors.Errorf("sub-option %d truncated", code)
+		}
+		v[code] = append(v[code], data[i+2:i+2+int(data[i+1])]...)
+		i += 2 + int(data[i+1])
+	}
+	return v, nil
+}
+
+// decode the value of the sub-option code as sub-options itself
+func (v VendorOptions) Sub(code byte) (VendorOptions, error) {
+	data, ok := v[code]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	sub, err := ParseVendorOptions(data)
+	return sub, errors.Wrapf(err, "sub-option %d", code)
+}
+
+// encode the sub-options sorted by code and followed by End. values
+// longer than 255 bytes are split over several sub-options
+func (v VendorOptions) MarshalBytes() []byte {
+	codes := make([]int, 0, len(v))
+	for k := range v {
+		codes = append(codes, int(k))
+	}
+	sort.Ints(codes)
+
+	var b []byte
+	for _, k := range codes {
+		data := v[byte(k)]
+		for {
+			n := len(data)
+			if n > 255 {
+				n = 255
+			}
+			b = append(b, byte(k), byte(n))
+			b = append(b, data[:n]...)
+			data = data[n:]
+			if len(data) == 0 {
+				break
+			}
+		}
+	}
+	return append(b, byte(OptionEnd))
+}
+
+// option 43, decoded as sub-options
+func (o Options) VendorOptions() (VendorOptions, error) {
+	data, ok := o[OptionVendorSpecificInformation]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	v, err := ParseVendorOptions(data)
+	if err != nil {
+		return nil, &OptionError{OptionVendorSpecificInformation, err}
+	}
+	return v, nil
+}
+
+// set option 43 to the encoded sub-options
+func (o Options) SetVendorOptions(v VendorOptions) error {
+	data := v.MarshalBytes()
+	if len(data) > 255 {
+		return &OptionError{OptionVendorSpecificInformation,
+			errors.Errorf("sub-options need %d bytes, more than 255", len(data))}
+	}
+	o[OptionVendorSpecificInformation] = data
+	return nil
+}
+
+// a VendorClass is the vendor class data of one vendor in option 124
+// (RFC3925), identified by its IANA enterprise number
+type VendorClass struct {
+	Enterprise uint32
+	Data       [][]byte
+}
+
+// a VendorInfo is the vendor-specific sub-options of one vendor in
+// option 125 (RFC3925), identified by its IANA enterprise number
+type VendorInfo struct {
+	Enterprise uint32
+	Options    VendorOptions
+}
+
+// option 124
+func (o Options) VIVendorClasses() ([]VendorClass, error) {
+	blocks, err := o.vendorBlocks(OptionVIVendorClass)
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make([]VendorClass, 0, len(blocks))
+	for _, b := range blocks {
+		c := VendorClass{Enterprise: b.enterprise}
+		for i := 0; i < len(b.data); {
+			n := int(b.data[i])
+			if i+1+n > len(b.data) {
+				return nil, &OptionError{OptionVIVendorClass,
+					errors.Errorf("class data of enterprise %d truncated", b.enterprise)}
+			}
+			c.Data = append(c.Data, b.data[i+1:i+1+n])
+			i += 1 + n
+		}
+		classes = append(classes, c)
+	}
+	return classes, nil
+}
+
+// set option 124
+func (o Options) SetVIVendorClasses(classes []VendorClass) error {
+	blocks := make([]vendorBlock, 0, len(classes))
+	for _, c := range classes {
+		var data []byte
+		for _, d := range c.Data {
+			if len(d) > 255 {
+				return &OptionError{OptionVIVendorClass, errors.Errorf("class data of %d bytes is too long", len(d))}
+			}
+			data = append(append(data, byte(len(d))), d...)
+		}
+		blocks = append(blocks, vendorBlock{c.Enterprise, data})
+	}
+	return o.setVendorBlocks(OptionVIVendorClass, blocks)
+}
+
+// option 125
+func (o Options) VIVendorInfo() ([]VendorInfo, error) {
+	blocks, err := o.vendorBlocks(OptionVIVendorSpecificInformation)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]VendorInfo, 0, len(blocks))
+	for _, b := range blocks {
+		opts, err := ParseVendorOptions(b.data)
+		if err != nil {
+			return nil, &OptionError{OptionVIVendorSpecificInformation,
+				errors.Wrapf(err, "enterprise %d", b.enterprise)}
+		}
+		infos = append(infos, VendorInfo{b.enterprise, opts})
+	}
+	return infos, nil
+}
+
+// set option 125. unlike in option 43, the sub-options of each vendor
+// are not followed by End
+func (o Options) SetVIVendorInfo(infos []VendorInfo) error {
+	blocks := make([]vendorBlock, 0, len(infos))
+	for _, info := range infos {
+		data := info.Options.MarshalBytes()
+		blocks = append(blocks, vendorBlock{info.Enterprise, data[:len(data)-1]})
+	}
+	return o.setVendorBlocks(OptionVIVendorSpecificInformation, blocks)
+}
+
+// the data of one enterprise in option 124 or 125
+type vendorBlock struct {
+	enterprise uint32
+	data       []byte
+}
+
+// get an option made of enterprise number, length and data blocks
+func (o Options) vendorBlocks(code OptionCode) ([]vendorBlock, error) {
+	v, ok := o[code]
+	if !ok {
+		return nil, ErrOptionNotPresent
+	}
+	if len(v) == 0 {
+		return nil, &OptionError{code, ErrShortRead}
+	}
+
+	var blocks []vendorBlock
+	for i := 0; i < len(v); {
+		if i+5 > len(v) || i+5+int(v[i+4]) > len(v) {
+			return nil, &OptionError{code, errors.New("vendor block truncated")}
+		}
+		blocks = append(blocks, vendorBlock{
+			enterprise: binary.BigEndian.Uint32(v[i : i+4]),
+			data:       v[i+5 : i+5+int(v[i+4])],
+		})
+		i += 5 + int(v[i+4])
+	}
+	return blocks, nil
+}
+
+// set an option made of enterprise number, length and data blocks
+func (o Options) setVendorBlocks(code OptionCode, blocks []vendorBlock) error {
+	if len(blocks) == 0 {
+		return &OptionError{code, errors.New("at least one vendor is needed")}
+	}
+	var v []byte
+	for _, b := range blocks {
+		if len(b.data) > 255 {
+			return &OptionError{code, errors.Errorf("data of enterprise %d is too long", b.enterprise)}
+		}
+		v = append(v, byte(b.enterprise>>24), byte(b.enterprise>>16), byte(b.enterprise>>8), byte(b.enterprise))
+		v = append(v, byte(len(b.data)))
+		v = append(v, b.data...)
+	}
+	if len(v) > 255 {
+		return &OptionError{code, errors.Errorf("vendor blocks need %d bytes, more than 255", len(v))}
+	}
+	o[code] = v
+	return nil
+}
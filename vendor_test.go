@@ -0,0 +1,119 @@
+package jdhcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVendorOptions(t *testing.T) {
+	// a PXE menu prompt and a nested set of sub-options, padded
+	data := []byte{
+		6, 1, 0x08,
+		0,
+		10, 3, 5, 'a', 'b',
+		200, 6, 1, 1, 'x', 2, 1, 'y',
+		255, 0, 0,
+	}
+	v, err := ParseVendorOptions(data)
+	if err != nil {
+		t.Fatalf("cannot parse vendor options: %s", err)
+	}
+	if len(v) != 3 || !bytes.Equal(v[10], []byte{5, 'a', 'b'}) {
+		t.Fatalf("unexpected vendor options %v", v)
+	}
+
+	sub, err := v.Sub(200)
+	if err != nil {
+		t.Fatalf("cannot parse nested sub-options: %s", err)
+	}
+	if string(sub[1]) != "x" || string(sub[2]) != "y" {
+		t.Errorf("unexpected nested sub-options %v", sub)
+	}
+	if _, err := v.Sub(99); err != ErrOptionNotPresent {
+		t.Errorf("expected ErrOptionNotPresent got %v", err)
+	}
+
+	want := []byte{6, 1, 0x08, 10, 3, 5, 'a', 'b', 200, 6, 1, 1, 'x', 2, 1, 'y', 255}
+	if got := v.MarshalBytes(); !bytes.Equal(got, want) {
+		t.Errorf("expected % x got % x", want, got)
+	}
+
+	if _, err := ParseVendorOptions([]byte{1, 5, 0}); err == nil {
+		t.Errorf("expected error for truncated sub-option")
+	}
+}
+
+func TestVendorOptionsLong(t *testing.T) {
+	v := VendorOptions{1: bytes.Repeat([]byte{'a'}, 300)}
+	b := v.MarshalBytes()
+	if len(b) != 2+255+2+45+1 {
+		t.Fatalf("expected value split over 2 sub-options, got %d bytes", len(b))
+	}
+
+	got, err := ParseVendorOptions(b)
+	if err != nil {
+		t.Fatalf("cannot parse vendor options: %s", err)
+	}
+	if len(got[1]) != 300 {
+		t.Errorf("expected split value to be joined, got %d bytes", len(got[1]))
+	}
+
+	o := make(Options)
+	if err := o.SetVendorOptions(v); err == nil {
+		t.Errorf("expected error for sub-options longer than option 43")
+	}
+	if err := o.SetVendorOptions(VendorOptions{1: []byte("x")}); err != nil {
+		t.Fatalf("o.SetVendorOptions() returned error: %s", err)
+	}
+	got, err = o.VendorOptions()
+	if err != nil || string(got[1]) != "x" {
+		t.Errorf("expected sub-option 1 to be x got %v (%v)", got, err)
+	}
+}
+
+func TestVIVendorClasses(t *testing.T) {
+	o := make(Options)
+	c1 := []VendorClass{{Enterprise: 3561, Data: [][]byte{[]byte("dslforum.org")}}}
+	err := o.SetVIVendorClasses(c1)
+	if err != nil {
+		t.Fatalf("o.SetVIVendorClasses() returned error: %s", err)
+	}
+	want := append([]byte{0, 0, 0x0d, 0xe9, 13, 12}, "dslforum.org"...)
+	if !bytes.Equal(o[OptionVIVendorClass], want) {
+		t.Fatalf("expected % x got % x", want, o[OptionVIVendorClass])
+	}
+
+	c2, err := o.VIVendorClasses()
+	if err != nil {
+		t.Fatalf("o.VIVendorClasses() returned error: %s", err)
+	}
+	if len(c2) != 1 || c2[0].Enterprise != 3561 || len(c2[0].Data) != 1 || string(c2[0].Data[0]) != "dslforum.org" {
+		t.Errorf("unexpected vendor classes %+v", c2)
+	}
+}
+
+func TestVIVendorInfo(t *testing.T) {
+	o := make(Options)
+	i1 := []VendorInfo{
+		{Enterprise: 3561, Options: VendorOptions{1: []byte("ABCDEF"), 2: []byte("123")}},
+		{Enterprise: 4491, Options: VendorOptions{2: {1, 2, 3, 4}}},
+	}
+	err := o.SetVIVendorInfo(i1)
+	if err != nil {
+		t.Fatalf("o.SetVIVendorInfo() returned error: %s", err)
+	}
+
+	i2, err := o.VIVendorInfo()
+	if err != nil {
+		t.Fatalf("o.VIVendorInfo() returned error: %s", err)
+	}
+	if len(i2) != 2 || i2[0].Enterprise != 3561 || string(i2[0].Options[2]) != "123" ||
+		i2[1].Enterprise != 4491 || !bytes.Equal(i2[1].Options[2], []byte{1, 2, 3, 4}) {
+		t.Errorf("unexpected vendor info %+v", i2)
+	}
+
+	o[OptionVIVendorSpecificInformation] = []byte{0, 0, 0x0d, 0xe9, 10, 1}
+	if _, err := o.VIVendorInfo(); err == nil {
+		t.Errorf("expected error for a truncated vendor block")
+	}
+}